@@ -7,6 +7,7 @@ require (
 	github.com/go-chi/jwtauth/v5 v5.3.1
 	github.com/lestrrat-go/jwx/v2 v2.0.21
 	github.com/stretchr/testify v1.9.0
+	go.etcd.io/bbolt v1.3.9
 	golang.org/x/crypto v0.22.0
 )
 
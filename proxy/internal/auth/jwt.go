@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"test/proxy/internal/config"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// JWTAuth signs tokens with the active key and verifies them against every
+// configured key, so tokens issued before a key rotation stay valid until the
+// old key is removed from the config.
+type JWTAuth struct {
+	alg             jwa.SignatureAlgorithm
+	signKey         jwk.Key
+	verifyKeys      jwk.Set
+	publicKeys      jwk.Set
+	accessTTL       time.Duration
+	refreshTTL      time.Duration
+	validateOptions []jwt.ValidateOption
+}
+
+func New(conf config.JWT, validateOptions ...jwt.ValidateOption) (*JWTAuth, error) {
+	var alg jwa.SignatureAlgorithm
+	if err := alg.Accept(conf.Algorithm); err != nil || keyTypes[family(alg)] == "" {
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", conf.Algorithm)
+	}
+	symmetric := family(alg) == "HS"
+
+	keys := make(map[string]jwk.Key, len(conf.Keys)+1)
+	for kid, path := range conf.Keys {
+		key, err := loadKey(path, symmetric)
+		if err != nil {
+			return nil, fmt.Errorf("error load jwt key %q: %v", kid, err)
+		}
+		keys[kid] = key
+	}
+	if len(conf.Keys) == 0 {
+		if !symmetric || conf.Secret == "" {
+			return nil, fmt.Errorf("no signing keys configured for %s", alg)
+		}
+		key, err := jwk.FromRaw([]byte(conf.Secret))
+		if err != nil {
+			return nil, fmt.Errorf("error load jwt secret: %v", err)
+		}
+		keys[conf.KeyID] = key
+	}
+
+	signKey, ok := keys[conf.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("active jwt key %q is not configured", conf.KeyID)
+	}
+	if !symmetric && !isPrivate(signKey) {
+		return nil, fmt.Errorf("active jwt key %q is not a private key", conf.KeyID)
+	}
+
+	ja := &JWTAuth{
+		alg:             alg,
+		signKey:         signKey,
+		verifyKeys:      jwk.NewSet(),
+		publicKeys:      jwk.NewSet(),
+		accessTTL:       conf.AccessTTL,
+		refreshTTL:      conf.RefreshTTL,
+		validateOptions: validateOptions,
+	}
+
+	kids := make([]string, 0, len(keys))
+	for kid := range keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := keys[kid]
+		if err := checkKeyType(alg, key); err != nil {
+			return nil, fmt.Errorf("jwt key %q: %v", kid, err)
+		}
+		if err := setKeyHeaders(key, kid, alg); err != nil {
+			return nil, err
+		}
+
+		verifyKey, err := jwk.PublicKeyOf(key)
+		if err != nil {
+			return nil, fmt.Errorf("error get public jwt key %q: %v", kid, err)
+		}
+		if err := setKeyHeaders(verifyKey, kid, alg); err != nil {
+			return nil, err
+		}
+		_ = ja.verifyKeys.AddKey(verifyKey)
+		if !symmetric {
+			_ = ja.publicKeys.AddKey(verifyKey)
+		}
+	}
+
+	return ja, nil
+}
+
+func (ja *JWTAuth) Encode(claims map[string]interface{}) (jwt.Token, string, error) {
+	t := jwt.New()
+	for k, v := range claims {
+		if err := t.Set(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+	payload, err := jwt.Sign(t, jwt.WithKey(ja.alg, ja.signKey))
+	if err != nil {
+		return nil, "", err
+	}
+	return t, string(payload), nil
+}
+
+// Decode verifies the token signature. Tokens with a "kid" header are checked
+// against that key only, legacy tokens without one against every known key.
+func (ja *JWTAuth) Decode(tokenString string) (jwt.Token, error) {
+	msg, err := jws.Parse([]byte(tokenString))
+	if err != nil {
+		return nil, err
+	}
+	requireKid := len(msg.Signatures()) > 0 && msg.Signatures()[0].ProtectedHeaders().KeyID() != ""
+
+	// validation is done separately with jwt.Validate and ValidateOptions
+	return jwt.Parse([]byte(tokenString), jwt.WithKeySet(ja.verifyKeys, jws.WithRequireKid(requireKid)), jwt.WithValidate(false))
+}
+
+func (ja *JWTAuth) ValidateOptions() []jwt.ValidateOption {
+	return ja.validateOptions
+}
+
+func (ja *JWTAuth) AccessTTL() time.Duration {
+	return ja.accessTTL
+}
+
+func (ja *JWTAuth) RefreshTTL() time.Duration {
+	return ja.refreshTTL
+}
+
+// JWKS returns the public keys other services need to verify our tokens.
+// It is empty for HMAC algorithms, whose keys must stay secret.
+func (ja *JWTAuth) JWKS() jwk.Set {
+	return ja.publicKeys
+}
+
+// Verifier is a drop-in replacement for jwtauth.Verifier that stores the
+// verified token in the jwtauth request context.
+func Verifier(ja *JWTAuth) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			token, err := ja.verifyRequest(r)
+			ctx := jwtauth.NewContext(r.Context(), token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+func (ja *JWTAuth) verifyRequest(r *http.Request) (jwt.Token, error) {
+	tokenString := jwtauth.TokenFromHeader(r)
+	if tokenString == "" {
+		tokenString = jwtauth.TokenFromCookie(r)
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+
+	token, err := ja.Decode(tokenString)
+	if err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	if err := jwt.Validate(token, ja.validateOptions...); err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	return token, nil
+}
+
+func loadKey(path string, symmetric bool) (jwk.Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if symmetric {
+		return jwk.FromRaw([]byte(strings.TrimSpace(string(data))))
+	}
+	return jwk.ParseKey(data, jwk.WithPEM(true))
+}
+
+func isPrivate(key jwk.Key) bool {
+	switch key.(type) {
+	case jwk.RSAPrivateKey, jwk.ECDSAPrivateKey, jwk.OKPPrivateKey:
+		return true
+	}
+	return false
+}
+
+var keyTypes = map[string]jwa.KeyType{
+	"HS": jwa.OctetSeq,
+	"RS": jwa.RSA,
+	"PS": jwa.RSA,
+	"ES": jwa.EC,
+}
+
+func family(alg jwa.SignatureAlgorithm) string {
+	if len(alg.String()) < 2 {
+		return ""
+	}
+	return alg.String()[:2]
+}
+
+func checkKeyType(alg jwa.SignatureAlgorithm, key jwk.Key) error {
+	if key.KeyType() != keyTypes[family(alg)] {
+		return fmt.Errorf("key type %s does not match algorithm %s", key.KeyType(), alg)
+	}
+	return nil
+}
+
+func setKeyHeaders(key jwk.Key, kid string, alg jwa.SignatureAlgorithm) error {
+	if kid != "" {
+		if err := key.Set(jwk.KeyIDKey, kid); err != nil {
+			return err
+		}
+	}
+	if err := key.Set(jwk.AlgorithmKey, alg); err != nil {
+		return err
+	}
+	return key.Set(jwk.KeyUsageKey, jwk.ForSignature)
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"test/proxy/internal/config"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePrivateKey(t *testing.T, key crypto.Signer) (string, string) {
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	private := filepath.Join(dir, "private.pem")
+	assert.NoError(t, os.WriteFile(private, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	der, err = x509.MarshalPKIXPublicKey(key.Public())
+	assert.NoError(t, err)
+	public := filepath.Join(dir, "public.pem")
+	assert.NoError(t, os.WriteFile(public, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	return private, public
+}
+
+func TestNew(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPrivate, rsaPublic := writePrivateKey(t, rsaKey)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecPrivate, _ := writePrivateKey(t, ecKey)
+
+	tests := []struct {
+		name    string
+		conf    config.JWT
+		wantErr bool
+	}{
+		{"1", config.JWT{Algorithm: "HS256", Secret: "salt_01"}, false},
+		{"2", config.JWT{Algorithm: "RS256", KeyID: "rsa1", Keys: map[string]string{"rsa1": rsaPrivate}}, false},
+		{"3", config.JWT{Algorithm: "ES256", KeyID: "ec1", Keys: map[string]string{"ec1": ecPrivate}}, false},
+		{"4", config.JWT{Algorithm: "none", Secret: "salt_01"}, true},
+		{"5", config.JWT{Algorithm: "RS256", Secret: "salt_01"}, true},
+		{"6", config.JWT{Algorithm: "RS256", KeyID: "rsa1", Keys: map[string]string{"rsa1": rsaPublic}}, true},
+		{"7", config.JWT{Algorithm: "ES256", KeyID: "rsa1", Keys: map[string]string{"rsa1": rsaPrivate}}, true},
+		{"8", config.JWT{Algorithm: "RS256", KeyID: "rsa2", Keys: map[string]string{"rsa1": rsaPrivate}}, true},
+		{"9", config.JWT{Algorithm: "RS256", KeyID: "rsa1", Keys: map[string]string{"rsa1": "missing.pem"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTAuth_EncodeDecode(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPrivate, _ := writePrivateKey(t, rsaKey)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecPrivate, _ := writePrivateKey(t, ecKey)
+
+	tests := []struct {
+		name    string
+		conf    config.JWT
+		wantKid string
+	}{
+		{"1", config.JWT{Algorithm: "HS256", Secret: "salt_01"}, ""},
+		{"2", config.JWT{Algorithm: "HS256", KeyID: "hs1", Secret: "salt_01"}, "hs1"},
+		{"3", config.JWT{Algorithm: "RS256", KeyID: "rsa1", Keys: map[string]string{"rsa1": rsaPrivate}}, "rsa1"},
+		{"4", config.JWT{Algorithm: "ES256", KeyID: "ec1", Keys: map[string]string{"ec1": ecPrivate}}, "ec1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ja, err := New(tt.conf)
+			assert.NoError(t, err)
+
+			_, tokenString, err := ja.Encode(map[string]interface{}{"login": "User1"})
+			assert.NoError(t, err)
+
+			msg, err := jws.Parse([]byte(tokenString))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantKid, msg.Signatures()[0].ProtectedHeaders().KeyID())
+			assert.Equal(t, tt.conf.Algorithm, msg.Signatures()[0].ProtectedHeaders().Algorithm().String())
+
+			token, err := ja.Decode(tokenString)
+			assert.NoError(t, err)
+			login, _ := token.Get("login")
+			assert.Equal(t, "User1", login)
+
+			_, err = ja.Decode(tokenString[:len(tokenString)-4] + "AAAA")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJWTAuth_Rotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	oldPrivate, oldPublic := writePrivateKey(t, oldKey)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newPrivate, _ := writePrivateKey(t, newKey)
+
+	before, err := New(config.JWT{Algorithm: "RS256", KeyID: "2024-01", Keys: map[string]string{"2024-01": oldPrivate}})
+	assert.NoError(t, err)
+	_, oldToken, _ := before.Encode(map[string]interface{}{"login": "User1"})
+
+	during, err := New(config.JWT{Algorithm: "RS256", KeyID: "2024-02", Keys: map[string]string{"2024-01": oldPublic, "2024-02": newPrivate}})
+	assert.NoError(t, err)
+	_, newToken, _ := during.Encode(map[string]interface{}{"login": "User1"})
+
+	after, err := New(config.JWT{Algorithm: "RS256", KeyID: "2024-02", Keys: map[string]string{"2024-02": newPrivate}})
+	assert.NoError(t, err)
+
+	_, err = during.Decode(oldToken)
+	assert.NoError(t, err)
+	_, err = during.Decode(newToken)
+	assert.NoError(t, err)
+	_, err = after.Decode(oldToken)
+	assert.Error(t, err)
+	_, err = before.Decode(newToken)
+	assert.Error(t, err)
+
+	assert.Equal(t, 2, during.JWKS().Len())
+	data, err := json.Marshal(during.JWKS())
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"kid":"2024-01"`)
+	assert.Contains(t, string(data), `"kid":"2024-02"`)
+	assert.NotContains(t, string(data), `"d":`)
+}
+
+func TestJWTAuth_JWKSHidesSecrets(t *testing.T) {
+	ja, err := New(config.JWT{Algorithm: "HS256", KeyID: "hs1", Secret: "salt_01"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ja.JWKS().Len())
+}
+
+func TestVerifier(t *testing.T) {
+	ja, err := New(config.JWT{Algorithm: "HS256", Secret: "salt_01"})
+	assert.NoError(t, err)
+	_, tokenString, _ := ja.Encode(map[string]interface{}{"login": "User1"})
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr error
+	}{
+		{"1", "Bearer " + tokenString, nil},
+		{"2", "Bearer invalid_token_string", jwtauth.ErrUnauthorized},
+		{"3", "", jwtauth.ErrNoTokenFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotErr error
+			handler := Verifier(ja)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _, gotErr = jwtauth.FromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.wantErr, gotErr)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the breaker rejects calls.
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	// Closed lets every call through and counts consecutive failures
+	Closed State = iota
+	// Open rejects every call until the cool-down has passed
+	Open
+	// HalfOpen lets a single probe call through at a time
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Stats is a snapshot of the breaker.
+type Stats struct {
+	State State
+	// Failures is the number of consecutive failures while closed
+	Failures int
+	// Opens is the number of times the breaker tripped
+	Opens uint64
+	// Rejected is the number of calls failed fast while open
+	Rejected uint64
+	// OpenedAt is when the breaker last tripped, zero if it never did
+	OpenedAt time.Time
+	// RetryAt is when an open breaker lets the next probe through
+	RetryAt time.Time
+}
+
+// Breaker trips after failureThreshold consecutive failures and rejects calls
+// for coolDown. Then it lets probes through one by one and closes again after
+// successThreshold of them succeed in a row, a failed probe reopens it.
+type Breaker struct {
+	failureThreshold int
+	successThreshold int
+	coolDown         time.Duration
+	now              func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	successes int
+	probing   bool
+	opens     uint64
+	rejected  uint64
+	openedAt  time.Time
+}
+
+func New(failureThreshold, successThreshold int, coolDown time.Duration) *Breaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if successThreshold < 1 {
+		successThreshold = 1
+	}
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
+		coolDown:         coolDown,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Done with its outcome, or by Release when it has none.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.coolDown)) {
+		b.state = HalfOpen
+		b.successes = 0
+	}
+
+	switch b.state {
+	case Open:
+		b.rejected++
+		return ErrOpen
+	case HalfOpen:
+		if b.probing {
+			b.rejected++
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Done records the outcome of a call let through by Allow.
+func (b *Breaker) Done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Closed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.trip()
+		}
+	case HalfOpen:
+		b.probing = false
+		if !success {
+			b.trip()
+			return
+		}
+		b.successes++
+		if b.successes >= b.successThreshold {
+			b.state = Closed
+			b.failures = 0
+		}
+	}
+}
+
+// Release ends a call let through by Allow that tells nothing about the
+// backend, such as one canceled by its caller. A probe frees its slot for
+// the next one without closing or reopening the breaker.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *Breaker) trip() {
+	b.state = Open
+	b.opens++
+	b.openedAt = b.now()
+	b.probing = false
+}
+
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := Stats{
+		State:    b.state,
+		Failures: b.failures,
+		Opens:    b.opens,
+		Rejected: b.rejected,
+		OpenedAt: b.openedAt,
+	}
+	if b.state == Open {
+		stats.RetryAt = b.openedAt.Add(b.coolDown)
+	}
+	return stats
+}
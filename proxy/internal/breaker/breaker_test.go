@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker_Trip(t *testing.T) {
+	b := New(3, 1, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, b.Allow())
+		b.Done(false)
+	}
+	// a success resets the consecutive failures
+	assert.NoError(t, b.Allow())
+	b.Done(true)
+	assert.Equal(t, 0, b.Stats().Failures)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, b.Allow())
+		b.Done(false)
+	}
+	assert.Equal(t, Open, b.Stats().State)
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+
+	stats := b.Stats()
+	assert.Equal(t, uint64(1), stats.Opens)
+	assert.Equal(t, uint64(2), stats.Rejected)
+	assert.Equal(t, stats.OpenedAt.Add(time.Minute), stats.RetryAt)
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	now := time.Now()
+	b := New(1, 2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.NoError(t, b.Allow())
+	b.Done(false)
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+
+	// a failed probe reopens the breaker for another cool-down
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, HalfOpen, b.Stats().State)
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+	b.Done(false)
+	assert.Equal(t, Open, b.Stats().State)
+	assert.Equal(t, now, b.Stats().OpenedAt)
+	now = now.Add(30 * time.Second)
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+
+	// successThreshold successful probes close it
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, b.Allow())
+	b.Done(true)
+	assert.Equal(t, HalfOpen, b.Stats().State)
+	assert.NoError(t, b.Allow())
+	b.Done(true)
+	assert.Equal(t, Closed, b.Stats().State)
+	assert.Equal(t, uint64(2), b.Stats().Opens)
+}
+
+func TestBreaker_Release(t *testing.T) {
+	now := time.Now()
+	b := New(1, 1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.NoError(t, b.Allow())
+	b.Done(false)
+	now = now.Add(time.Minute)
+
+	// a released probe neither closes nor reopens the breaker
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+	b.Release()
+	assert.Equal(t, HalfOpen, b.Stats().State)
+	assert.NoError(t, b.Allow())
+	b.Done(true)
+	assert.Equal(t, Closed, b.Stats().State)
+
+	// nor does it reset the failures while closed
+	b = New(2, 1, time.Minute)
+	assert.NoError(t, b.Allow())
+	b.Done(false)
+	assert.NoError(t, b.Allow())
+	b.Release()
+	assert.Equal(t, 1, b.Stats().Failures)
+}
+
+func TestState_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		state State
+		want  string
+	}{
+		{"1", Closed, "closed"},
+		{"2", Open, "open"},
+		{"3", HalfOpen, "half-open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.state.String())
+		})
+	}
+}
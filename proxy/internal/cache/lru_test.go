@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU_GetSet(t *testing.T) {
+	c := NewLRU(2, 0)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// "b" is the least recently used now
+	c.Set("c", 3)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+
+	c.Set("a", 4)
+	v, _ = c.Get("a")
+	assert.Equal(t, 4, v)
+
+	assert.Equal(t, Stats{Hits: 3, Misses: 2, Evictions: 1, Size: 2}, c.Stats())
+}
+
+func TestLRU_TTL(t *testing.T) {
+	now := time.Now()
+	c := NewLRU(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Stats().Size)
+}
+
+func TestLRU_Flush(t *testing.T) {
+	c := NewLRU(10, 0)
+	c.Set("a", 1)
+	c.Get("a")
+	c.Flush()
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Size: 0}, c.Stats())
+}
+
+func TestLRU_Concurrent(t *testing.T) {
+	c := NewLRU(50, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("%d", (i*j)%80)
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, j)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(2000), stats.Hits+stats.Misses)
+	assert.LessOrEqual(t, stats.Size, 50)
+}
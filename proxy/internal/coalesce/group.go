@@ -0,0 +1,109 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var errPanicked = errors.New("coalesced call panicked")
+
+// Stats are cumulative counters since the group was created.
+type Stats struct {
+	// Calls is the number of times fn was actually executed
+	Calls uint64
+	// Coalesced is the number of callers that shared another caller's result
+	Coalesced uint64
+	// InFlight is the number of keys currently being executed
+	InFlight int
+}
+
+type call struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	val     interface{}
+	err     error
+	waiters int
+	dups    int
+}
+
+// Group deduplicates concurrent calls with the same key: fn is executed once
+// and everyone asking for the key while it runs gets its result.
+type Group struct {
+	mu        sync.Mutex
+	calls     map[string]*call
+	executed  uint64
+	coalesced uint64
+}
+
+func NewGroup() *Group {
+	return &Group{calls: make(map[string]*call)}
+}
+
+// Do executes fn once per key at a time. Each caller stops waiting when its
+// own ctx is done. fn gets a separate context that is canceled only when no
+// caller is waiting anymore, so one client going away does not fail the
+// others. shared reports whether the result was handed to more than one
+// caller.
+func (g *Group) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (v interface{}, err error, shared bool) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if ok {
+		c.waiters++
+		c.dups++
+		g.coalesced++
+	} else {
+		callCtx, cancel := context.WithCancel(context.Background())
+		c = &call{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		g.calls[key] = c
+		g.executed++
+		go g.run(callCtx, key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err, c.dups > 0
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			g.forget(key, c)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err(), false
+	}
+}
+
+func (g *Group) run(ctx context.Context, key string, c *call, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		// a panic must not take down the process nor hang the waiters
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("%w: %v", errPanicked, r)
+		}
+		g.mu.Lock()
+		g.forget(key, c)
+		g.mu.Unlock()
+		c.cancel()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn(ctx)
+}
+
+// forget removes the call unless a newer one took over the key, the caller
+// must hold the lock.
+func (g *Group) forget(key string, c *call) {
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
+
+func (g *Group) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return Stats{Calls: g.executed, Coalesced: g.coalesced, InFlight: len(g.calls)}
+}
@@ -0,0 +1,132 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Do(t *testing.T) {
+	g := NewGroup()
+
+	v, err, shared := g.Do(context.Background(), "a", func(ctx context.Context) (interface{}, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.False(t, shared)
+
+	_, err, _ = g.Do(context.Background(), "a", func(ctx context.Context) (interface{}, error) { return nil, errors.New("upstream error") })
+	assert.Error(t, err)
+
+	assert.Equal(t, Stats{Calls: 2}, g.Stats())
+}
+
+func TestGroup_DoConcurrent(t *testing.T) {
+	g := NewGroup()
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	calls := 0
+	fn := func(ctx context.Context) (interface{}, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return "result", nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]interface{}, callers)
+	shares := make([]bool, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, shares[i] = g.Do(context.Background(), "a", fn)
+		}(i)
+	}
+
+	// wait until every caller is parked on the same call
+	for g.Stats().Coalesced < callers-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, calls)
+	for i := range results {
+		assert.Equal(t, "result", results[i])
+		assert.True(t, shares[i])
+	}
+	assert.Equal(t, Stats{Calls: 1, Coalesced: callers - 1}, g.Stats())
+}
+
+func TestGroup_DoCancel(t *testing.T) {
+	g := NewGroup()
+	canceled := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-ctx.Done():
+			close(canceled)
+			return nil, ctx.Err()
+		case <-release:
+			return "result", nil
+		}
+	}
+
+	// one of two callers leaving does not cancel the shared call
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err, _ := g.Do(ctx1, "a", fn)
+		errs <- err
+	}()
+	for g.Stats().InFlight < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	results := make(chan interface{}, 1)
+	go func() {
+		v, _, _ := g.Do(context.Background(), "a", fn)
+		results <- v
+	}()
+	for g.Stats().Coalesced < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel1()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	close(release)
+	assert.Equal(t, "result", <-results)
+
+	// the call is canceled once its last caller leaves
+	release = make(chan struct{})
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	go func() {
+		_, err, _ := g.Do(ctx2, "b", fn)
+		errs <- err
+	}()
+	for g.Stats().InFlight < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel2()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("shared call was not canceled")
+	}
+}
+
+func TestGroup_DoPanic(t *testing.T) {
+	g := NewGroup()
+
+	_, err, _ := g.Do(context.Background(), "a", func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	assert.ErrorIs(t, err, errPanicked)
+	assert.Equal(t, 0, g.Stats().InFlight)
+}
@@ -0,0 +1,455 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environments, anything but EnvDevelopment and EnvTest refuses to start with
+// secrets left unset
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+)
+
+type Config struct {
+	// Env is the deployment environment, production unless set otherwise
+	Env string
+	// Providers are the geocoding backends tried in order, the next one is
+	// asked when the previous fails or finds nothing
+	Providers []string
+	DaData    DaData
+	Retry     Retry
+	Gazetteer Gazetteer
+	Cache     Cache
+	Breaker   Breaker
+	// Coalesce shares one provider call between concurrent identical lookups
+	Coalesce bool
+	// Batch bounds the batch search and geocoding endpoints
+	Batch     Batch
+	UserStore UserStore
+	// Revocation is the store of revoked token IDs
+	Revocation  Revocation
+	APIKeyStore APIKeyStore
+	// UsageStore keeps the daily call counts of paid providers
+	UsageStore UsageStore
+	// AuditLog is the audit trail of authentication, geocoding and admin
+	// requests
+	AuditLog AuditLog
+	// HistoryLimit is the number of lookups kept per user, 0 disables the
+	// search history
+	HistoryLimit int
+	HistoryStore HistoryStore
+	JWT          JWT
+	// RateLimit bounds inbound requests per user, or per client IP before
+	// login
+	RateLimit RateLimit
+	// Lockout protects /api/login from password guessing
+	Lockout Lockout
+	// Password is the policy for passwords chosen on registration
+	Password Password
+	// Admins are the logins granted the admin role when they register
+	Admins []string
+}
+
+type DaData struct {
+	SearchHost string
+	GeoHost    string
+	APIKey     string
+	SecretKey  string
+	// ConnectTimeout bounds establishing the connection to the API
+	ConnectTimeout time.Duration
+	// Timeout bounds a whole API call, including reading the response
+	Timeout time.Duration
+	Limit   Limit
+}
+
+// Limit is the budget of a paid geocoding provider
+type Limit struct {
+	// Rate of outbound calls per second, 0 means no limit
+	Rate float64
+	// Burst is the number of calls allowed at once above the rate
+	Burst int
+	// MaxWait is how long a call may queue for the rate limit before it is
+	// rejected
+	MaxWait time.Duration
+	// DailyQuota is the number of calls per UTC day, 0 means no limit
+	DailyQuota int
+}
+
+// Retry is the policy for transient errors of the geocoding provider
+type Retry struct {
+	// MaxAttempts including the first call, 1 disables retries
+	MaxAttempts int
+	// BaseDelay before the first retry, doubled for every next one
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts
+	MaxDelay time.Duration
+	// Jitter spreads each delay by up to this fraction of it, 0 to 1
+	Jitter float64
+	// RetryableStatus are the upstream HTTP statuses worth retrying
+	RetryableStatus []int
+}
+
+type Gazetteer struct {
+	// File is a CSV or GeoJSON dataset of addresses with coordinates
+	File string
+	// Limit caps the number of search results, 0 means no limit
+	Limit int
+	// NearestK caps the number of reverse geocoding results, 0 means no limit
+	NearestK int
+	// Radius in meters for reverse geocoding, 0 means no limit
+	Radius float64
+	// CellSize of the spatial index grid in degrees
+	CellSize float64
+}
+
+type Cache struct {
+	// Size is the maximum number of cached responses, 0 disables the cache
+	Size int
+	// TTL of a cached response, 0 keeps it until it is evicted
+	TTL time.Duration
+	// Precision is the number of decimals coordinates are rounded to in
+	// cache keys, 4 decimals are about 11 meters
+	Precision int
+}
+
+// Breaker stops calling a geocoding provider while it keeps failing, each
+// provider has a breaker of its own
+type Breaker struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker, 0 disables it
+	FailureThreshold int
+	// SuccessThreshold is the number of successful probes that closes it
+	SuccessThreshold int
+	// CoolDown is how long the breaker stays open before probing again
+	CoolDown time.Duration
+}
+
+type Batch struct {
+	// MaxSize is the number of queries accepted in one batch
+	MaxSize int
+	// Concurrency is the number of queries of a batch looked up at once
+	Concurrency int
+}
+
+type Lockout struct {
+	// MaxFailures within Window lock a login, 0 disables the protection
+	MaxFailures int
+	// MaxIPFailures within Window lock a client IP
+	MaxIPFailures int
+	// Window after the first failed attempt in which failures are counted
+	Window time.Duration
+	// Duration of a lockout
+	Duration time.Duration
+	// BaseDelay is the wait after the first failed attempt of a login, doubled
+	// with every further one
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts
+	MaxDelay time.Duration
+}
+
+type Password struct {
+	// MinLength in characters
+	MinLength int
+	// MinClasses of lower case letters, upper case letters, digits and
+	// symbols a password must mix
+	MinClasses int
+	// CheckCommon rejects passwords from the list of common passwords
+	CheckCommon bool
+	// BcryptCost of the stored password hashes
+	BcryptCost int
+}
+
+type UserStore struct {
+	// Driver is either "bolt" or "memory", which loses every account on
+	// restart and is meant for tests
+	Driver string
+	// File is the bbolt database of the bolt driver
+	File string
+}
+
+type Revocation struct {
+	// Driver is either "file" or "memory", with which revoked tokens are
+	// valid again after a restart
+	Driver string
+	File   string
+}
+
+type APIKeyStore struct {
+	// Driver is either "file" or "memory", which loses every key on restart
+	Driver string
+	File   string
+}
+
+type UsageStore struct {
+	// Driver is either "memory" or "file"
+	Driver string
+	File   string
+	// FlushInterval is how often changed counts are written to File, 0
+	// writes every call right away
+	FlushInterval time.Duration
+}
+
+type HistoryStore struct {
+	// Driver is either "memory" or "file"
+	Driver string
+	// Dir holds a file per user for the file driver
+	Dir string
+}
+
+type AuditLog struct {
+	// Driver is either "memory" or "file"
+	Driver string
+	File   string
+	// MaxSize in bytes the file is rotated at, 0 never rotates it
+	MaxSize int
+	// MaxFiles is the number of rotated files kept, 0 keeps all of them
+	MaxFiles int
+}
+
+type RateLimit struct {
+	// Default applies to every route not listed in Routes
+	Default Rate
+	// Routes are the limits of single routes, e.g. "/api/login"
+	Routes map[string]Rate
+}
+
+type Rate struct {
+	// PerSecond is the sustained number of requests, 0 means no limit
+	PerSecond float64
+	// Burst is the number of requests allowed at once
+	Burst int
+}
+
+type JWT struct {
+	// Algorithm is one of HS256, RS256, ES256 or their 384/512 variants
+	Algorithm string
+	// KeyID of the active signing key, sent in the "kid" token header
+	KeyID string
+	// Secret is the HMAC key used when Keys is empty
+	Secret string
+	// Keys maps key IDs to key files: PEM for RSA/ECDSA, raw bytes for HMAC.
+	// Every key verifies tokens, only KeyID signs them.
+	Keys map[string]string
+	// AccessTTL is the lifetime of access tokens
+	AccessTTL time.Duration
+	// RefreshTTL is the lifetime of refresh tokens
+	RefreshTTL time.Duration
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Env:       getEnv("APP_ENV", EnvProduction),
+		Providers: getEnvList("GEO_PROVIDER", []string{"dadata"}),
+		DaData: DaData{
+			SearchHost:     getEnv("DADATA_SEARCH_HOST", "https://cleaner.dadata.ru/api/v1/clean/address"),
+			GeoHost:        getEnv("DADATA_GEO_HOST", "http://suggestions.dadata.ru/suggestions/api/4_1/rs/geolocate/address"),
+			APIKey:         getEnv("DADATA_API_KEY", ""),
+			SecretKey:      getEnv("DADATA_SECRET_KEY", ""),
+			ConnectTimeout: getEnvDuration("DADATA_CONNECT_TIMEOUT", 3*time.Second),
+			Timeout:        getEnvDuration("DADATA_TIMEOUT", 10*time.Second),
+			Limit: Limit{
+				Rate:       getEnvFloat("DADATA_RATE_LIMIT", 10),
+				Burst:      getEnvInt("DADATA_RATE_BURST", 10),
+				MaxWait:    getEnvDuration("DADATA_RATE_MAX_WAIT", time.Second),
+				DailyQuota: getEnvInt("DADATA_DAILY_QUOTA", 10000),
+			},
+		},
+		Retry: Retry{
+			MaxAttempts:     getEnvInt("GEO_RETRY_ATTEMPTS", 3),
+			BaseDelay:       getEnvDuration("GEO_RETRY_BASE_DELAY", 100*time.Millisecond),
+			MaxDelay:        getEnvDuration("GEO_RETRY_MAX_DELAY", 2*time.Second),
+			Jitter:          getEnvFloat("GEO_RETRY_JITTER", 0.2),
+			RetryableStatus: getEnvIntList("GEO_RETRY_STATUS", []int{429, 500, 502, 503, 504}),
+		},
+		Gazetteer: Gazetteer{
+			File:     getEnv("GAZETTEER_FILE", "./data/gazetteer.csv"),
+			Limit:    getEnvInt("GAZETTEER_LIMIT", 10),
+			NearestK: getEnvInt("GAZETTEER_NEAREST_K", 5),
+			Radius:   getEnvFloat("GAZETTEER_RADIUS", 1000),
+			CellSize: getEnvFloat("GAZETTEER_CELL_SIZE", 0.01),
+		},
+		Cache: Cache{
+			Size:      getEnvInt("GEO_CACHE_SIZE", 1000),
+			TTL:       getEnvDuration("GEO_CACHE_TTL", time.Hour),
+			Precision: getEnvInt("GEO_CACHE_PRECISION", 4),
+		},
+		Breaker: Breaker{
+			FailureThreshold: getEnvInt("GEO_BREAKER_FAILURES", 5),
+			SuccessThreshold: getEnvInt("GEO_BREAKER_SUCCESSES", 1),
+			CoolDown:         getEnvDuration("GEO_BREAKER_COOLDOWN", 30*time.Second),
+		},
+		Coalesce: getEnvBool("GEO_COALESCE", true),
+		Batch: Batch{
+			MaxSize:     getEnvInt("BATCH_MAX_SIZE", 500),
+			Concurrency: getEnvInt("BATCH_CONCURRENCY", 8),
+		},
+		UserStore: UserStore{
+			Driver: getEnv("USER_STORE", "bolt"),
+			File:   getEnv("USER_STORE_FILE", "./data/users.db"),
+		},
+		Revocation: Revocation{
+			Driver: getEnv("REVOCATION_STORE", "file"),
+			File:   getEnv("REVOCATION_STORE_FILE", "./data/revoked.json"),
+		},
+		APIKeyStore: APIKeyStore{
+			Driver: getEnv("API_KEY_STORE", "file"),
+			File:   getEnv("API_KEY_STORE_FILE", "./data/apikeys.json"),
+		},
+		UsageStore: UsageStore{
+			Driver:        getEnv("USAGE_STORE", "file"),
+			File:          getEnv("USAGE_STORE_FILE", "./data/usage.json"),
+			FlushInterval: getEnvDuration("USAGE_STORE_FLUSH_INTERVAL", 5*time.Second),
+		},
+		AuditLog: AuditLog{
+			Driver:   getEnv("AUDIT_LOG", "file"),
+			File:     getEnv("AUDIT_LOG_FILE", "./data/audit.log"),
+			MaxSize:  getEnvInt("AUDIT_LOG_MAX_SIZE", 10<<20),
+			MaxFiles: getEnvInt("AUDIT_LOG_MAX_FILES", 10),
+		},
+		HistoryLimit: getEnvInt("SEARCH_HISTORY_LIMIT", 50),
+		HistoryStore: HistoryStore{
+			Driver: getEnv("HISTORY_STORE", "file"),
+			Dir:    getEnv("HISTORY_STORE_DIR", "./data/history"),
+		},
+		JWT: JWT{
+			Algorithm:  getEnv("JWT_ALG", "HS256"),
+			KeyID:      getEnv("JWT_KEY_ID", ""),
+			Secret:     getEnv("JWT_SECRET", ""),
+			Keys:       getEnvMap("JWT_KEYS"),
+			AccessTTL:  getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute),
+			RefreshTTL: getEnvDuration("JWT_REFRESH_TTL", 30*24*time.Hour),
+		},
+		RateLimit: RateLimit{
+			Default: Rate{
+				PerSecond: getEnvFloat("RATE_LIMIT", 10),
+				Burst:     getEnvInt("RATE_LIMIT_BURST", 20),
+			},
+			Routes: getEnvRates("RATE_LIMIT_ROUTES", map[string]Rate{
+				"/api/login":    {PerSecond: 0.2, Burst: 5},
+				"/api/register": {PerSecond: 0.2, Burst: 5},
+			}),
+		},
+		Lockout: Lockout{
+			MaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+			MaxIPFailures: getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+			Window:        getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			Duration:      getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+			BaseDelay:     getEnvDuration("LOGIN_DELAY", time.Second),
+			MaxDelay:      getEnvDuration("LOGIN_MAX_DELAY", 30*time.Second),
+		},
+		Password: Password{
+			MinLength:   getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			MinClasses:  getEnvInt("PASSWORD_MIN_CLASSES", 3),
+			CheckCommon: getEnvBool("PASSWORD_CHECK_COMMON", true),
+			BcryptCost:  getEnvInt("BCRYPT_COST", 10),
+		},
+		Admins: getEnvList("ADMIN_LOGINS", nil),
+	}
+}
+
+// IsDev reports whether Env was explicitly set to development or test, the
+// only environments allowed to run on development defaults.
+func (c *Config) IsDev() bool {
+	return c.Env == EnvDevelopment || c.Env == EnvTest
+}
+
+func getEnv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func getEnvInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func getEnvFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+func getEnvBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// getEnvMap parses "key1=value1,key2=value2" lists
+func getEnvMap(key string) map[string]string {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) != "" {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
+
+// getEnvRates parses "route1=rate:burst,route2=rate:burst" lists, falling back
+// to def when unset
+func getEnvRates(key string, def map[string]Rate) map[string]Rate {
+	pairs := getEnvMap(key)
+	if len(pairs) == 0 {
+		return def
+	}
+	rates := make(map[string]Rate, len(pairs))
+	for route, v := range pairs {
+		perSecond, burst, _ := strings.Cut(v, ":")
+		rate := Rate{}
+		rate.PerSecond, _ = strconv.ParseFloat(strings.TrimSpace(perSecond), 64)
+		rate.Burst, _ = strconv.Atoi(strings.TrimSpace(burst))
+		rates[route] = rate
+	}
+	return rates
+}
+
+// getEnvIntList parses "1,2,3" lists, falling back to def on any error
+func getEnvIntList(key string, def []int) []int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	var list []int
+	for _, item := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return def
+		}
+		list = append(list, n)
+	}
+	return list
+}
+
+// getEnvList parses "value1,value2" lists, falling back to def when empty
+func getEnvList(key string, def []string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 0 {
+		return def
+	}
+	return list
+}
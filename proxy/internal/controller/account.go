@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"test/proxy/internal/service"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// ChangePassword sets a new password for the caller. Every token issued
+// before is revoked, the response carries a new token pair.
+func (c *Controller) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	reqInput := &ChangePasswordRequest{}
+	err = c.Decode(r.Body, reqInput)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	tokens, err := c.service.ChangePassword(token.Subject(), reqInput.Password, reqInput.NewPassword, clientIP(r))
+	var invalid *service.ValidationError
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		c.ErrorForbidden(w)
+		return
+	case errors.As(err, &invalid):
+		c.ErrorValidation(w, invalid.Errors)
+		return
+	case c.throttled(w, err):
+		return
+	case err != nil:
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	c.OutputJSON(w, tokenResponse(tokens))
+}
+
+// DeleteAccount removes the caller together with their API keys, every token
+// of the caller stops working.
+func (c *Controller) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	reqInput := &DeleteAccountRequest{}
+	err = c.Decode(r.Body, reqInput)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	err = c.service.DeleteAccount(token.Subject(), reqInput.Password, clientIP(r))
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		c.ErrorForbidden(w)
+		return
+	case c.throttled(w, err):
+		return
+	case err != nil:
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:model changePasswordRequest
+type ChangePasswordRequest struct {
+	// current password
+	//
+	// required: true
+	// example: Sukhonskaya-11
+	Password string `json:"password"`
+	// new password, it must pass the same policy as on registration
+	//
+	// required: true
+	// example: Sukhonskaya-13
+	NewPassword string `json:"new_password"`
+}
+
+// swagger:model deleteAccountRequest
+type DeleteAccountRequest struct {
+	// current password
+	//
+	// required: true
+	// example: Sukhonskaya-11
+	Password string `json:"password"`
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/service"
+	"test/proxy/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+const (
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
+)
+
+// AdminUsers lists users page by page. The login query parameter limits the
+// list to logins containing it.
+func (c *Controller) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	offset, limit, err := queryPage(r, defaultUsersLimit, maxUsersLimit)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	page, err := c.service.ListUsers(r.URL.Query().Get("login"), offset, limit)
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	resp := responder.UsersResponse{Users: make([]*responder.UserResponse, 0, len(page.Users)), Total: page.Total, Offset: offset, Limit: limit}
+	for _, user := range page.Users {
+		resp.Users = append(resp.Users, userResponse(user))
+	}
+	c.OutputJSON(w, resp)
+}
+
+// AdminUser shows the user in the URL on GET and deletes it on DELETE.
+func (c *Controller) AdminUser(w http.ResponseWriter, r *http.Request) {
+	login := chi.URLParam(r, "login")
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := c.service.GetUser(login)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			c.ErrorNotFound(w)
+			return
+		}
+		if err != nil {
+			c.ErrorInternal(w, err)
+			return
+		}
+		c.OutputJSON(w, userResponse(user))
+	case http.MethodDelete:
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err != nil || token == nil {
+			c.ErrorForbidden(w)
+			return
+		}
+		c.adminResult(w, c.service.DeleteUser(token.Subject(), login))
+	default:
+		c.ErrorNotAllowed(w)
+	}
+}
+
+func (c *Controller) DisableUser(w http.ResponseWriter, r *http.Request) {
+	c.setUserDisabled(w, r, true)
+}
+
+func (c *Controller) EnableUser(w http.ResponseWriter, r *http.Request) {
+	c.setUserDisabled(w, r, false)
+}
+
+func (c *Controller) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	c.adminResult(w, c.service.SetUserDisabled(token.Subject(), chi.URLParam(r, "login"), disabled))
+}
+
+// UserRoles replaces the roles of the user in the URL.
+func (c *Controller) UserRoles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	reqInput := &RolesRequest{}
+	err = c.Decode(r.Body, reqInput)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	c.adminResult(w, c.service.SetUserRoles(token.Subject(), chi.URLParam(r, "login"), reqInput.Roles))
+}
+
+// adminResult responds to a user management action with no content on
+// success.
+func (c *Controller) adminResult(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		c.ErrorNotFound(w)
+	case errors.Is(err, service.ErrUnknownRole), errors.Is(err, service.ErrSelfLockout):
+		c.ErrorBadRequest(w, err)
+	case err != nil:
+		c.ErrorInternal(w, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// swagger:model rolesRequest
+type RolesRequest struct {
+	// roles replacing the current ones, any of user, admin and batch
+	//
+	// required: true
+	// example: ["user","batch"]
+	Roles []string `json:"roles"`
+}
+
+func userResponse(user storage.User) *responder.UserResponse {
+	roles := user.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return &responder.UserResponse{Login: user.Login, Roles: roles, Disabled: user.Disabled}
+}
+
+// queryInt parses the query parameter name, def when it is missing.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// queryPage parses the offset and limit query parameters of a paginated
+// list, limit is def when it is missing and may not exceed max.
+func queryPage(r *http.Request, def, max int) (offset, limit int, err error) {
+	offset, err = queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("offset must be a non-negative number")
+	}
+	limit, err = queryInt(r, "limit", def)
+	if err != nil || limit < 1 || limit > max {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", max)
+	}
+	return offset, limit, nil
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/service"
+	"test/proxy/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyVerifier must run after auth.Verifier. Requests carrying an API key
+// get the token of the key owner in the jwtauth context instead, so the
+// Authenticator and RequireRole treat both kinds of callers the same way.
+func (c *Controller) APIKeyVerifier(next http.Handler) http.Handler {
+	hfn := func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := c.service.AuthenticateAPIKey(key)
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			c.ErrorUnauthorized(w)
+			return
+		}
+		if err != nil {
+			c.ErrorInternal(w, err)
+			return
+		}
+
+		ctx := jwtauth.NewContext(r.Context(), token, nil)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(hfn)
+}
+
+// RequireScope must run after Authenticator, it rejects API keys issued
+// without the scope. Interactive sessions are not limited by scopes.
+func (c *Controller) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil || token == nil {
+				c.ErrorForbidden(w)
+				return
+			}
+			if scopes, limited := service.TokenScopes(token); limited && !containsAny(scopes, []string{scope}) {
+				c.ErrorForbidden(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// APIKeys lists the keys of the caller on GET and issues a new one on POST.
+func (c *Controller) APIKeys(w http.ResponseWriter, r *http.Request) {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := c.service.ListAPIKeys(token.Subject())
+		if err != nil {
+			c.ErrorInternal(w, err)
+			return
+		}
+
+		resp := responder.APIKeysResponse{Keys: make([]*responder.APIKeyResponse, 0, len(keys))}
+		for _, key := range keys {
+			resp.Keys = append(resp.Keys, apiKeyResponse(key))
+		}
+		c.OutputJSON(w, resp)
+	case http.MethodPost:
+		reqInput := &APIKeyRequest{}
+		err := c.Decode(r.Body, reqInput)
+		if err != nil {
+			c.ErrorBadRequest(w, err)
+			return
+		}
+		if reqInput.ExpiresIn < 0 {
+			c.ErrorBadRequest(w, errors.New("expires_in must not be negative"))
+			return
+		}
+		// a key may only hand on scopes it was granted itself
+		if granted, limited := service.TokenScopes(token); limited {
+			requested := reqInput.Scopes
+			if len(requested) == 0 {
+				requested = service.DefaultAPIKeyScopes
+			}
+			for _, scope := range requested {
+				if !containsAny(granted, []string{scope}) {
+					c.ErrorForbidden(w)
+					return
+				}
+			}
+		}
+
+		issued, err := c.service.CreateAPIKey(token.Subject(), reqInput.Name, reqInput.Scopes, time.Duration(reqInput.ExpiresIn)*time.Second)
+		if errors.Is(err, service.ErrInvalidScope) {
+			c.ErrorBadRequest(w, err)
+			return
+		}
+		if err != nil {
+			c.ErrorInternal(w, err)
+			return
+		}
+
+		resp := apiKeyResponse(issued.APIKey)
+		resp.Key = issued.Key
+		c.OutputJSON(w, resp)
+	default:
+		c.ErrorNotAllowed(w)
+	}
+}
+
+func (c *Controller) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	err = c.service.DeleteAPIKey(token.Subject(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		c.ErrorNotFound(w)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:model apiKeyRequest
+type APIKeyRequest struct {
+	// label to tell keys apart
+	//
+	// example: nightly import
+	Name string `json:"name"`
+	// endpoints the key may call, address:search and address:geocode by default.
+	// A key creating keys may only grant scopes it has itself.
+	//
+	// example: ["address:search"]
+	Scopes []string `json:"scopes"`
+	// key lifetime in seconds, 0 for a key that never expires
+	//
+	// example: 2592000
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+func apiKeyResponse(key storage.APIKey) *responder.APIKeyResponse {
+	resp := &responder.APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.ExpiresAt.IsZero() {
+		expiresAt := key.ExpiresAt
+		resp.ExpiresAt = &expiresAt
+	}
+	return resp
+}
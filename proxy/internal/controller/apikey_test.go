@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"test/proxy/internal/auth"
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+	"test/proxy/internal/service"
+	"test/proxy/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ptflp/godecoder"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestController_APIKeys(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := responder.NewResponder(decoder, logger)
+	serv := service.NewGeoService(service.NewDaData(decoder, config.DaData{}), storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	contrl := NewController(respond, decoder, serv)
+
+	tokens, err := serv.Register("User1", "qwerty")
+	assert.NoError(t, err)
+	searchKey, err := serv.CreateAPIKey("User1", "", []string{service.ScopeAddressSearch}, 0)
+	assert.NoError(t, err)
+	keysKey, err := serv.CreateAPIKey("User1", "", []string{service.ScopeKeys}, 0)
+	assert.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(auth.Verifier(testTokenAuth))
+	r.Use(contrl.APIKeyVerifier)
+	r.Use(contrl.Authenticator(testTokenAuth))
+	r.With(contrl.RequireScope(service.ScopeAddressSearch)).HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.With(contrl.RequireScope(service.ScopeKeys)).HandleFunc("/keys", contrl.APIKeys)
+	r.With(contrl.RequireScope(service.ScopeKeys)).HandleFunc("/keys/{id}", contrl.DeleteAPIKey)
+
+	bearer := "Bearer " + tokens.AccessToken
+
+	type args struct {
+		method string
+		url    string
+		body   string
+		token  string
+		key    string
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{"1", args{"GET", "/search", "", "", searchKey.Key}, http.StatusOK},
+		{"2", args{"GET", "/search", "", "", keysKey.Key}, http.StatusForbidden},
+		{"3", args{"GET", "/search", "", "", "gk_unknown"}, http.StatusUnauthorized},
+		{"4", args{"GET", "/search", "", bearer, ""}, http.StatusOK},
+		{"5", args{"GET", "/keys", "", "", searchKey.Key}, http.StatusForbidden},
+		{"6", args{"GET", "/keys", "", "", keysKey.Key}, http.StatusOK},
+		{"7", args{"POST", "/keys", `{"name":"job","scopes":["unknown"]}`, bearer, ""}, http.StatusBadRequest},
+		{"8", args{"POST", "/keys", `{"name":"job","expires_in":-1}`, bearer, ""}, http.StatusBadRequest},
+		{"9", args{"POST", "/keys", `d`, bearer, ""}, http.StatusBadRequest},
+		{"10", args{"POST", "/keys", `{"name":"job","expires_in":3600}`, bearer, ""}, http.StatusOK},
+		{"11", args{"PUT", "/keys", `{}`, bearer, ""}, http.StatusMethodNotAllowed},
+		{"12", args{"GET", "/keys/" + searchKey.ID, "", bearer, ""}, http.StatusMethodNotAllowed},
+		{"13", args{"DELETE", "/keys/" + searchKey.ID, "", bearer, ""}, http.StatusNoContent},
+		{"14", args{"DELETE", "/keys/" + searchKey.ID, "", bearer, ""}, http.StatusNotFound},
+		{"15", args{"GET", "/search", "", "", searchKey.Key}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.args.method, tt.args.url, strings.NewReader(tt.args.body))
+			if tt.args.token != "" {
+				req.Header.Set("Authorization", tt.args.token)
+			}
+			if tt.args.key != "" {
+				req.Header.Set(APIKeyHeader, tt.args.key)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/keys", nil)
+	req.Header.Set("Authorization", bearer)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	resp := responder.APIKeysResponse{}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Len(t, resp.Keys, 2)
+	for _, key := range resp.Keys {
+		assert.Empty(t, key.Key)
+	}
+	assert.NotNil(t, resp.Keys[1].ExpiresAt)
+}
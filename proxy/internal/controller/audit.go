@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type auditKey struct{}
+
+// Audit records every request it wraps in the audit trail as action, with the
+// status it was answered with and how long that took. An empty action
+// records the method and route pattern instead. Handlers add details through
+// auditEvent, the caller defaults to the subject of the request token.
+// Nothing is recorded without an audit trail.
+func (c *Controller) Audit(action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if c.auditor == nil {
+			return next
+		}
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			event := &storage.AuditEvent{Time: start, Action: action, IP: clientIP(r)}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), auditKey{}, event)))
+
+			event.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+			event.Status = ww.Status()
+			if event.Status == 0 {
+				event.Status = http.StatusOK
+			}
+			if event.Action == "" {
+				event.Action = r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+			}
+			if event.Target == "" {
+				event.Target = chi.URLParam(r, "login")
+			}
+			if token, _, err := jwtauth.FromContext(r.Context()); event.Actor == "" && err == nil && token != nil {
+				event.Actor = token.Subject()
+			}
+			c.auditor.Audit(*event)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// auditEvent is the event the Audit middleware records for r. Outside of the
+// middleware changes to it are dropped.
+func auditEvent(r *http.Request) *storage.AuditEvent {
+	if event, ok := r.Context().Value(auditKey{}).(*storage.AuditEvent); ok {
+		return event
+	}
+	return &storage.AuditEvent{}
+}
+
+// AuditEvents returns the most recent events of the audit trail, optionally
+// of one user only and within a time range.
+func (c *Controller) AuditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	filter := storage.AuditFilter{Actor: r.URL.Query().Get("user")}
+	var err error
+	if filter.From, err = queryTime(r, "from"); err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	if filter.To, err = queryTime(r, "to"); err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	filter.Limit, err = queryInt(r, "limit", defaultAuditLimit)
+	if err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+		c.ErrorBadRequest(w, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit))
+		return
+	}
+
+	events := []storage.AuditEvent{}
+	if c.auditor != nil {
+		events, err = c.auditor.AuditEvents(filter)
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	resp := responder.AuditEventsResponse{Events: make([]*responder.AuditEventResponse, 0, len(events))}
+	for _, event := range events {
+		resp.Events = append(resp.Events, &responder.AuditEventResponse{
+			Time:      event.Time,
+			Actor:     event.Actor,
+			Action:    event.Action,
+			Target:    event.Target,
+			IP:        event.IP,
+			Status:    event.Status,
+			Query:     event.Query,
+			Results:   event.Results,
+			LatencyMs: event.LatencyMs,
+		})
+	}
+	c.OutputJSON(w, resp)
+}
+
+// queryTime parses the RFC 3339 query parameter name, zero when it is
+// missing.
+func queryTime(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time", name)
+	}
+	return t, nil
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/service"
+)
+
+// maxBatchBody bounds the body of batch requests, it is read before the
+// number of items is known.
+const maxBatchBody = 1 << 20
+
+// auditBatchItems is the number of items of a batch listed in its audit
+// event.
+const auditBatchItems = 10
+
+// GeoSearchBatch searches for every query of the request. One failing query
+// fails only its own result. Batch lookups are not added to the search
+// history, they would push out everything else.
+func (c *Controller) GeoSearchBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	reqInput := &BatchSearchRequest{}
+	err := c.Decode(http.MaxBytesReader(w, r.Body, maxBatchBody), reqInput)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	if len(reqInput.Queries) == 0 {
+		c.ErrorBadRequest(w, errors.New("queries must not be empty"))
+		return
+	}
+
+	event := auditEvent(r)
+	event.Query = batchQuery(reqInput.Queries)
+
+	results, err := c.service.SearchBatch(r.Context(), reqInput.Queries)
+	if errors.Is(err, service.ErrBatchTooLarge) {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	resp := responder.BatchResponse{Results: make([]*responder.BatchResult, 0, len(results))}
+	found := 0
+	for i, result := range results {
+		if result.Err != nil {
+			resp.Results = append(resp.Results, c.batchError(r, i, result.Err))
+			continue
+		}
+		found += len(result.Response.Addresses)
+		resp.Results = append(resp.Results, &responder.BatchResult{Status: http.StatusOK, Addresses: result.Response.Addresses, Provider: result.Response.Provider})
+	}
+	event.Results = &found
+
+	c.OutputJSON(w, resp)
+}
+
+// GeoCodeBatch looks up the addresses at every point of the request. One
+// failing point fails only its own result.
+func (c *Controller) GeoCodeBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	reqInput := &BatchGeocodeRequest{}
+	err := c.Decode(http.MaxBytesReader(w, r.Body, maxBatchBody), reqInput)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	if len(reqInput.Points) == 0 {
+		c.ErrorBadRequest(w, errors.New("points must not be empty"))
+		return
+	}
+
+	points := make([]service.GeocodePoint, 0, len(reqInput.Points))
+	queries := make([]string, 0, len(reqInput.Points))
+	for i, point := range reqInput.Points {
+		// a null item decodes as a point without coordinates
+		if point.Lat == "" || point.Lng == "" {
+			c.ErrorBadRequest(w, fmt.Errorf("points[%d] must have lat and lng", i))
+			return
+		}
+		points = append(points, service.GeocodePoint{Lat: point.Lat, Lon: point.Lng})
+		queries = append(queries, point.Lat+","+point.Lng)
+	}
+
+	event := auditEvent(r)
+	event.Query = batchQuery(queries)
+
+	results, err := c.service.GeocodeBatch(r.Context(), points)
+	if errors.Is(err, service.ErrBatchTooLarge) {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	resp := responder.BatchResponse{Results: make([]*responder.BatchResult, 0, len(results))}
+	found := 0
+	for i, result := range results {
+		if result.Err != nil {
+			resp.Results = append(resp.Results, c.batchError(r, i, result.Err))
+			continue
+		}
+		found += len(result.Response.Addresses)
+		resp.Results = append(resp.Results, &responder.BatchResult{Status: http.StatusOK, Addresses: result.Response.Addresses, Provider: result.Response.Provider})
+	}
+	event.Results = &found
+
+	c.OutputJSON(w, resp)
+}
+
+// batchError is the result of the failed item i, with the status geoError
+// would respond with to a single lookup. Server errors are logged, the batch
+// itself still succeeds.
+func (c *Controller) batchError(r *http.Request, i int, err error) *responder.BatchResult {
+	status, _ := c.geoStatus(err)
+	if status >= http.StatusInternalServerError && !errors.Is(r.Context().Err(), context.Canceled) {
+		c.LogItemError(i, status, err)
+	}
+	return &responder.BatchResult{Status: status, Error: fmt.Sprintf("%d %s: %v", status, http.StatusText(status), err)}
+}
+
+// batchQuery lists the first auditBatchItems items of a batch for its audit
+// event.
+func batchQuery(items []string) string {
+	if len(items) <= auditBatchItems {
+		return strings.Join(items, " | ")
+	}
+	return fmt.Sprintf("%s | … %d more", strings.Join(items[:auditBatchItems], " | "), len(items)-auditBatchItems)
+}
+
+// swagger:model batchSearchRequest
+type BatchSearchRequest struct {
+	// searching address queries
+	//
+	// required: true
+	// example: ["Москва, Сухонская 11","Санкт-Петербург, Невский проспект 1"]
+	Queries []string `json:"queries"`
+}
+
+// swagger:model batchGeocodeRequest
+type BatchGeocodeRequest struct {
+	// required: true
+	Points []GeocodeRequest `json:"points"`
+}
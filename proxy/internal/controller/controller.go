@@ -1,13 +1,25 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"html/template"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"test/proxy/internal/auth"
+	"test/proxy/internal/breaker"
+	"test/proxy/internal/cache"
+	"test/proxy/internal/coalesce"
+	"test/proxy/internal/ratelimit"
 	"test/proxy/internal/responder"
 	"test/proxy/internal/service"
+	"test/proxy/internal/storage"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/ptflp/godecoder"
@@ -16,23 +28,82 @@ import (
 type Controllerer interface {
 	Register(http.ResponseWriter, *http.Request)
 	Login(http.ResponseWriter, *http.Request)
+	Refresh(http.ResponseWriter, *http.Request)
 	GeoSearch(http.ResponseWriter, *http.Request)
 	GeoCode(http.ResponseWriter, *http.Request)
-	Authenticator(*jwtauth.JWTAuth) func(http.Handler) http.Handler
+	GeoSearchBatch(http.ResponseWriter, *http.Request)
+	GeoCodeBatch(http.ResponseWriter, *http.Request)
+	Logout(http.ResponseWriter, *http.Request)
+	ChangePassword(http.ResponseWriter, *http.Request)
+	DeleteAccount(http.ResponseWriter, *http.Request)
+	RevokeToken(http.ResponseWriter, *http.Request)
+	Cache(http.ResponseWriter, *http.Request)
+	Coalescing(http.ResponseWriter, *http.Request)
+	UnlockUser(http.ResponseWriter, *http.Request)
+	AdminUsers(http.ResponseWriter, *http.Request)
+	AdminUser(http.ResponseWriter, *http.Request)
+	DisableUser(http.ResponseWriter, *http.Request)
+	EnableUser(http.ResponseWriter, *http.Request)
+	UserRoles(http.ResponseWriter, *http.Request)
+	AuditEvents(http.ResponseWriter, *http.Request)
+	History(http.ResponseWriter, *http.Request)
+	Places(http.ResponseWriter, *http.Request)
+	Place(http.ResponseWriter, *http.Request)
+	NearbyPlaces(http.ResponseWriter, *http.Request)
+	Audit(action string) func(http.Handler) http.Handler
+	Health(http.ResponseWriter, *http.Request)
+	Authenticator(*auth.JWTAuth) func(http.Handler) http.Handler
+	RequireRole(roles ...string) func(http.Handler) http.Handler
+	APIKeyVerifier(http.Handler) http.Handler
+	RequireScope(scope string) func(http.Handler) http.Handler
+	RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler
+	APIKeys(http.ResponseWriter, *http.Request)
+	DeleteAPIKey(http.ResponseWriter, *http.Request)
+	JWKS(http.ResponseWriter, *http.Request)
 	SwaggerUI(http.ResponseWriter, *http.Request)
 }
 
 type Controller struct {
 	service service.GeoServicer
+	// the features of the decorators wrapping service, nil when not
+	// configured
+	unlocker   service.Unlocker
+	auditor    service.Auditor
+	history    service.HistoryKeeper
+	cache      service.Cacher
+	coalescing service.CoalescingStater
+	breakers   service.BreakerStater
 	responder.Responder
 	godecoder.Decoder
 }
 
-func NewController(resp responder.Responder, decod godecoder.Decoder, service service.GeoServicer) Controllerer {
-	return &Controller{Responder: resp, Decoder: decod, service: service}
+func NewController(resp responder.Responder, decod godecoder.Decoder, serv service.GeoServicer) Controllerer {
+	c := &Controller{Responder: resp, Decoder: decod, service: serv}
+	// the outermost decorator of a feature wins
+	for s := serv; s != nil; s = service.Unwrap(s) {
+		if v, ok := s.(service.Unlocker); ok && c.unlocker == nil {
+			c.unlocker = v
+		}
+		if v, ok := s.(service.Auditor); ok && c.auditor == nil {
+			c.auditor = v
+		}
+		if v, ok := s.(service.HistoryKeeper); ok && c.history == nil {
+			c.history = v
+		}
+		if v, ok := s.(service.Cacher); ok && c.cache == nil {
+			c.cache = v
+		}
+		if v, ok := s.(service.CoalescingStater); ok && c.coalescing == nil {
+			c.coalescing = v
+		}
+		if v, ok := s.(service.BreakerStater); ok && c.breakers == nil {
+			c.breakers = v
+		}
+	}
+	return c
 }
 
-func (c *Controller) Authenticator(ja *jwtauth.JWTAuth) func(http.Handler) http.Handler {
+func (c *Controller) Authenticator(ja *auth.JWTAuth) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		hfn := func(w http.ResponseWriter, r *http.Request) {
 			token, _, err := jwtauth.FromContext(r.Context())
@@ -42,12 +113,55 @@ func (c *Controller) Authenticator(ja *jwtauth.JWTAuth) func(http.Handler) http.
 				return
 			}
 
+			if tokenType, _ := token.Get(service.TokenTypeClaim); tokenType != service.AccessTokenType {
+				c.ErrorForbidden(w)
+				return
+			}
+
+			revoked, err := c.service.IsRevoked(token)
+			if err != nil {
+				c.ErrorInternal(w, err)
+				return
+			}
+			if revoked {
+				c.ErrorUnauthorized(w)
+				return
+			}
+
 			next.ServeHTTP(w, r)
 		}
 		return http.HandlerFunc(hfn)
 	}
 }
 
+// RequireRole must run after Authenticator, it lets through tokens carrying
+// any of the roles.
+func (c *Controller) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil || token == nil || !containsAny(service.TokenRoles(token), roles) {
+				c.ErrorForbidden(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+func containsAny(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		c.ErrorNotAllowed(w)
@@ -60,16 +174,29 @@ func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
 		c.ErrorBadRequest(w, err)
 		return
 	}
+	auditEvent(r).Actor = userInput.Login
 
 	if ok := c.service.IsUserExist(userInput.Login); ok {
 		c.ErrorUserConflict(w)
 		return
 	}
 
-	tokenString := c.service.Register(userInput.Login, userInput.Password)
+	tokens, err := c.service.Register(userInput.Login, userInput.Password)
+	var invalid *service.ValidationError
+	if errors.As(err, &invalid) {
+		c.ErrorValidation(w, invalid.Errors)
+		return
+	}
+	if errors.Is(err, storage.ErrUserExists) {
+		c.ErrorUserConflict(w)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
 
-	token := responder.TokenResponse{AccessToken: "Bearer " + tokenString}
-	c.OutputJSON(w, token)
+	c.OutputJSON(w, tokenResponse(tokens))
 }
 
 // swagger:model userRequest
@@ -80,7 +207,7 @@ type UserRequest struct {
 	Login string `json:"login"`
 	// user password
 	//
-	// example: qwerty
+	// example: Sukhonskaya-11
 	Password string `json:"password"`
 }
 
@@ -96,15 +223,338 @@ func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
 		c.ErrorBadRequest(w, err)
 		return
 	}
+	auditEvent(r).Actor = userInput.Login
 
-	tokenString, ok := c.service.Login(userInput.Login, userInput.Password)
-	if !ok {
+	tokens, err := c.service.Login(userInput.Login, userInput.Password, clientIP(r))
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
 		c.ErrorUserNotFound(w)
 		return
+	case errors.Is(err, service.ErrAccountDisabled):
+		c.ErrorForbidden(w)
+		return
+	case c.throttled(w, err):
+		return
+	case err != nil:
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	c.OutputJSON(w, tokenResponse(tokens))
+}
+
+// throttled responds to a password check refused by LockoutGeoService and
+// reports whether err was one.
+func (c *Controller) throttled(w http.ResponseWriter, err error) bool {
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	setRetryAfter(w, throttled.RetryAfter)
+	if errors.Is(err, service.ErrAccountLocked) {
+		c.ErrorLocked(w, err)
+	} else {
+		c.ErrorTooManyRequests(w, err)
+	}
+	return true
+}
+
+func (c *Controller) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	reqInput := &RefreshRequest{}
+	err := c.Decode(r.Body, reqInput)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	tokens, err := c.service.Refresh(reqInput.RefreshToken)
+	if errors.Is(err, service.ErrInvalidToken) {
+		c.ErrorUnauthorized(w)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+	auditEvent(r).Actor = tokens.Login
+
+	c.OutputJSON(w, tokenResponse(tokens))
+}
+
+// swagger:model refreshRequest
+type RefreshRequest struct {
+	// refresh token returned by login or register
+	//
+	// required: true
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (c *Controller) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	// the body is optional, without it only the access token is revoked
+	reqInput := &LogoutRequest{}
+	err := c.Decode(r.Body, reqInput)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+	// tokens of API keys share the jti of the key, revoking one would lock the
+	// key out until the token expires. Keys are deleted instead.
+	if _, limited := service.TokenScopes(token); limited {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	err = c.service.Logout(token, reqInput.RefreshToken)
+	if errors.Is(err, service.ErrInvalidToken) {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
 	}
 
-	token := responder.TokenResponse{AccessToken: "Bearer " + tokenString}
-	c.OutputJSON(w, token)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:model logoutRequest
+type LogoutRequest struct {
+	// refresh token to revoke together with the access token
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (c *Controller) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	reqInput := &RevokeRequest{}
+	err := c.Decode(r.Body, reqInput)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	err = c.service.RevokeToken(reqInput.Token)
+	if errors.Is(err, service.ErrInvalidToken) {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:model revokeRequest
+type RevokeRequest struct {
+	// access or refresh token to revoke, without the "Bearer " prefix
+	//
+	// required: true
+	Token string `json:"token"`
+}
+
+// Cache reports the geocoding cache counters on GET and flushes it on DELETE.
+func (c *Controller) Cache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var stats cache.Stats
+		if c.cache != nil {
+			stats = c.cache.CacheStats()
+		}
+		resp := responder.CacheStatsResponse{
+			Hits:      stats.Hits,
+			Misses:    stats.Misses,
+			Evictions: stats.Evictions,
+			Size:      stats.Size,
+		}
+		if lookups := stats.Hits + stats.Misses; lookups > 0 {
+			resp.HitRatio = float64(stats.Hits) / float64(lookups)
+		}
+		c.OutputJSON(w, resp)
+	case http.MethodDelete:
+		if c.cache != nil {
+			c.cache.FlushCache()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		c.ErrorNotAllowed(w)
+	}
+}
+
+// UnlockUser lifts the lockout of the login in the URL after failed login
+// attempts.
+func (c *Controller) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	login := chi.URLParam(r, "login")
+	if !c.service.IsUserExist(login) {
+		c.ErrorNotFound(w)
+		return
+	}
+
+	if c.unlocker != nil {
+		c.unlocker.UnlockLogin(login)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Controller) Coalescing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	var stats coalesce.Stats
+	if c.coalescing != nil {
+		stats = c.coalescing.CoalescingStats()
+	}
+	c.OutputJSON(w, responder.CoalescingStatsResponse{
+		Calls:     stats.Calls,
+		Coalesced: stats.Coalesced,
+		InFlight:  stats.InFlight,
+	})
+}
+
+// Health reports whether the geocoding providers are available. It answers
+// 200 while breakers are open too, the service itself is still up.
+func (c *Controller) Health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	providers := c.breakerStats()
+	resp := responder.HealthResponse{Status: "ok", Providers: make([]responder.ProviderHealthResponse, 0, len(providers))}
+	for _, stats := range providers {
+		provider := responder.ProviderHealthResponse{
+			Name: stats.Provider,
+			Breaker: responder.BreakerResponse{
+				State:    stats.State.String(),
+				Failures: stats.Failures,
+				Opens:    stats.Opens,
+				Rejected: stats.Rejected,
+			},
+		}
+		if stats.State != breaker.Closed {
+			resp.Status = "degraded"
+		}
+		if !stats.OpenedAt.IsZero() {
+			provider.Breaker.OpenedAt = &stats.OpenedAt
+		}
+		if !stats.RetryAt.IsZero() {
+			provider.Breaker.RetryAt = &stats.RetryAt
+		}
+		resp.Providers = append(resp.Providers, provider)
+	}
+	c.OutputJSON(w, resp)
+}
+
+func (c *Controller) breakerStats() []service.ProviderBreakerStats {
+	if c.breakers == nil {
+		return nil
+	}
+	return c.breakers.BreakerStats()
+}
+
+// geoError maps a failed provider lookup to a response. Nothing is written
+// when the client has gone away.
+func (c *Controller) geoError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		return
+	}
+	status, retryAfter := c.geoStatus(err)
+	if retryAfter > 0 {
+		setRetryAfter(w, retryAfter)
+	}
+	switch status {
+	case http.StatusBadRequest:
+		c.ErrorBadRequest(w, err)
+	case http.StatusTooManyRequests:
+		c.ErrorTooManyRequests(w, err)
+	case http.StatusServiceUnavailable:
+		c.ErrorServiceUnavailable(w, err)
+	case http.StatusGatewayTimeout:
+		c.ErrorGatewayTimeout(w, err)
+	default:
+		c.ErrorInternal(w, err)
+	}
+}
+
+// geoStatus is the status a failed provider lookup is answered with, and how
+// long the client should wait before trying again, zero when unknown.
+func (c *Controller) geoStatus(err error) (int, time.Duration) {
+	var limitErr *service.RateLimitError
+	switch {
+	case errors.Is(err, service.ErrInvalidCoordinates):
+		return http.StatusBadRequest, 0
+	case errors.As(err, &limitErr):
+		// at least a second, even when the quota is already renewed
+		return http.StatusTooManyRequests, maxDuration(limitErr.RetryAfter, time.Second)
+	case errors.Is(err, breaker.ErrOpen):
+		// the first provider to let a lookup through again
+		var retryAt time.Time
+		for _, stats := range c.breakerStats() {
+			if !stats.RetryAt.IsZero() && (retryAt.IsZero() || stats.RetryAt.Before(retryAt)) {
+				retryAt = stats.RetryAt
+			}
+		}
+		if retryAt.IsZero() {
+			return http.StatusServiceUnavailable, 0
+		}
+		return http.StatusServiceUnavailable, maxDuration(time.Until(retryAt), time.Second)
+	case service.IsTimeout(err):
+		return http.StatusGatewayTimeout, 0
+	default:
+		return http.StatusInternalServerError, 0
+	}
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, at least one.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+func tokenResponse(tokens *service.Tokens) responder.TokenResponse {
+	return responder.TokenResponse{
+		AccessToken:  "Bearer " + tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+	}
 }
 
 func (c *Controller) GeoSearch(w http.ResponseWriter, r *http.Request) {
@@ -120,11 +570,17 @@ func (c *Controller) GeoSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	addrSearch, err := c.service.GetSearchResp(reqInput.Query)
+	event := auditEvent(r)
+	event.Query = reqInput.Query
+
+	addrSearch, err := c.service.GetSearchResp(r.Context(), reqInput.Query)
 	if err != nil {
-		c.ErrorInternal(w, err)
+		c.geoError(w, r, err)
 		return
 	}
+	results := len(addrSearch.Addresses)
+	event.Results = &results
+	c.recordHistory(r, storage.HistorySearch, reqInput.Query, addrSearch.Addresses)
 
 	c.OutputJSON(w, addrSearch)
 }
@@ -142,15 +598,30 @@ func (c *Controller) GeoCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	addrGeoCode, err := c.service.GetGeoResp(reqInput.Lat, reqInput.Lng)
+	event := auditEvent(r)
+	event.Query = reqInput.Lat + "," + reqInput.Lng
+
+	addrGeoCode, err := c.service.GetGeoResp(r.Context(), reqInput.Lat, reqInput.Lng)
 	if err != nil {
-		c.ErrorInternal(w, err)
+		c.geoError(w, r, err)
 		return
 	}
+	results := len(addrGeoCode.Addresses)
+	event.Results = &results
+	c.recordHistory(r, storage.HistoryGeocode, event.Query, addrGeoCode.Addresses)
 
 	c.OutputJSON(w, addrGeoCode)
 }
 
+func (c *Controller) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	c.OutputJSON(w, c.service.JWKS())
+}
+
 // swagger:model searchRequest
 type SearchRequest struct {
 	// searching address query
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// History lists the lookups of the caller page by page on GET, most recent
+// first, and clears them on DELETE. It answers 404 while the search history
+// is disabled.
+func (c *Controller) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		c.ErrorNotAllowed(w)
+		return
+	}
+	if c.history == nil {
+		c.ErrorNotFound(w)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		err := c.history.ClearHistory(token.Subject())
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			c.ErrorNotFound(w)
+		case err != nil:
+			c.ErrorInternal(w, err)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	offset, limit, err := queryPage(r, defaultHistoryLimit, maxHistoryLimit)
+	if err != nil {
+		c.ErrorBadRequest(w, err)
+		return
+	}
+
+	page, err := c.history.History(token.Subject(), offset, limit)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		c.ErrorNotFound(w)
+		return
+	}
+	if err != nil {
+		c.ErrorInternal(w, err)
+		return
+	}
+
+	resp := responder.HistoryResponse{Entries: make([]*responder.HistoryEntryResponse, 0, len(page.Entries)), Total: page.Total, Offset: offset, Limit: limit}
+	for _, entry := range page.Entries {
+		addresses := make([]*responder.Address, 0, len(entry.Addresses))
+		for _, addr := range entry.Addresses {
+			addresses = append(addresses, &responder.Address{Address: addr.Address, Lat: addr.Lat, Lon: addr.Lon})
+		}
+		resp.Entries = append(resp.Entries, &responder.HistoryEntryResponse{Time: entry.Time, Kind: entry.Kind, Query: entry.Query, Addresses: addresses})
+	}
+	c.OutputJSON(w, resp)
+}
+
+// recordHistory adds a successful lookup to the history of the caller.
+func (c *Controller) recordHistory(r *http.Request, kind, query string, found []*responder.Address) {
+	if c.history == nil {
+		return
+	}
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil || token.Subject() == "" {
+		return
+	}
+
+	addresses := make([]storage.Address, 0, len(found))
+	for _, addr := range found {
+		addresses = append(addresses, storage.Address{Address: addr.Address, Lat: addr.Lat, Lon: addr.Lon})
+	}
+	c.history.RecordHistory(token.Subject(), storage.HistoryEntry{Kind: kind, Query: query, Addresses: addresses})
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/service"
+	"test/proxy/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+const (
+	defaultNearbyRadius = 1000
+	maxNearbyRadius     = 50000
+	defaultNearbyLimit  = 20
+	maxNearbyLimit      = 100
+)
+
+// Places lists the saved places of the caller on GET, optionally filtered by
+// the tag and q query parameters, and saves a new one on POST.
+func (c *Controller) Places(w http.ResponseWriter, r *http.Request) {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		filter := service.PlaceFilter{Tag: r.URL.Query().Get("tag"), Query: r.URL.Query().Get("q")}
+		places, err := c.service.ListPlaces(token.Subject(), filter)
+		if err != nil {
+			c.placeError(w, err)
+			return
+		}
+
+		resp := responder.PlacesResponse{Places: make([]*responder.PlaceResponse, 0, len(places))}
+		for _, place := range places {
+			resp.Places = append(resp.Places, placeResponse(place))
+		}
+		c.OutputJSON(w, resp)
+	case http.MethodPost:
+		reqInput := &PlaceRequest{}
+		err := c.Decode(r.Body, reqInput)
+		if err != nil {
+			c.ErrorBadRequest(w, err)
+			return
+		}
+
+		place, err := c.service.CreatePlace(token.Subject(), reqInput.place(""))
+		if err != nil {
+			c.placeError(w, err)
+			return
+		}
+		c.OutputJSON(w, placeResponse(place))
+	default:
+		c.ErrorNotAllowed(w)
+	}
+}
+
+// Place shows the saved place in the URL on GET, replaces it on PUT and
+// deletes it on DELETE.
+func (c *Controller) Place(w http.ResponseWriter, r *http.Request) {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	switch r.Method {
+	case http.MethodGet:
+		place, err := c.service.GetPlace(token.Subject(), id)
+		if err != nil {
+			c.placeError(w, err)
+			return
+		}
+		c.OutputJSON(w, placeResponse(place))
+	case http.MethodPut:
+		reqInput := &PlaceRequest{}
+		err := c.Decode(r.Body, reqInput)
+		if err != nil {
+			c.ErrorBadRequest(w, err)
+			return
+		}
+
+		place, err := c.service.UpdatePlace(token.Subject(), reqInput.place(id))
+		if err != nil {
+			c.placeError(w, err)
+			return
+		}
+		c.OutputJSON(w, placeResponse(place))
+	case http.MethodDelete:
+		if err := c.service.DeletePlace(token.Subject(), id); err != nil {
+			c.placeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		c.ErrorNotAllowed(w)
+	}
+}
+
+// NearbyPlaces lists the saved places of the caller around the lat and lng
+// query parameters, nearest first.
+func (c *Controller) NearbyPlaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.ErrorNotAllowed(w)
+		return
+	}
+
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		c.ErrorForbidden(w)
+		return
+	}
+
+	lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.ErrorBadRequest(w, errors.New("lat must be a number between -90 and 90"))
+		return
+	}
+	lng, err := strconv.ParseFloat(r.URL.Query().Get("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		c.ErrorBadRequest(w, errors.New("lng must be a number between -180 and 180"))
+		return
+	}
+	radius, err := queryInt(r, "radius", defaultNearbyRadius)
+	if err != nil || radius < 1 || radius > maxNearbyRadius {
+		c.ErrorBadRequest(w, fmt.Errorf("radius must be between 1 and %d meters", maxNearbyRadius))
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultNearbyLimit)
+	if err != nil || limit < 1 || limit > maxNearbyLimit {
+		c.ErrorBadRequest(w, fmt.Errorf("limit must be between 1 and %d", maxNearbyLimit))
+		return
+	}
+
+	nearby, err := c.service.NearbyPlaces(token.Subject(), lat, lng, float64(radius), limit)
+	if err != nil {
+		c.placeError(w, err)
+		return
+	}
+
+	resp := responder.PlacesResponse{Places: make([]*responder.PlaceResponse, 0, len(nearby))}
+	for _, place := range nearby {
+		placeResp := placeResponse(place.Place)
+		placeResp.Distance = place.Distance
+		resp.Places = append(resp.Places, placeResp)
+	}
+	c.OutputJSON(w, resp)
+}
+
+func (c *Controller) placeError(w http.ResponseWriter, err error) {
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		c.ErrorValidation(w, invalid.Errors)
+	case errors.Is(err, storage.ErrUserNotFound), errors.Is(err, storage.ErrPlaceNotFound):
+		c.ErrorNotFound(w)
+	default:
+		c.ErrorInternal(w, err)
+	}
+}
+
+// swagger:model placeRequest
+type PlaceRequest struct {
+	// address as returned by /api/address/search
+	//
+	// required: true
+	Address *responder.Address `json:"address"`
+	// required: true
+	// max length: 100
+	// example: Home
+	Label string `json:"label"`
+	// example: ["family"]
+	Tags []string `json:"tags"`
+	// max length: 1000
+	// example: code 42 at the gate
+	Notes string `json:"notes"`
+}
+
+func (p *PlaceRequest) place(id string) storage.Place {
+	place := storage.Place{ID: id, Label: p.Label, Tags: p.Tags, Notes: p.Notes}
+	if p.Address != nil {
+		place.Address = storage.Address{Address: p.Address.Address, Lat: p.Address.Lat, Lon: p.Address.Lon}
+	}
+	return place
+}
+
+func placeResponse(place storage.Place) *responder.PlaceResponse {
+	tags := place.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return &responder.PlaceResponse{
+		ID:        place.ID,
+		Address:   &responder.Address{Address: place.Address.Address, Lat: place.Lat, Lon: place.Lon},
+		Label:     place.Label,
+		Tags:      tags,
+		Notes:     place.Notes,
+		CreatedAt: place.CreatedAt,
+		UpdatedAt: place.UpdatedAt,
+	}
+}
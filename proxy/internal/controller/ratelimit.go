@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"test/proxy/internal/ratelimit"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// RateLimit limits requests per user, or per client IP when the request
+// carries no token. Every response reports the budget in X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset, the seconds until it is
+// restored in full; rejected requests also get Retry-After.
+func (c *Controller) RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Allow(rateLimitKey(r))
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(res.Reset.Seconds()))))
+			if !res.Allowed {
+				setRetryAfter(w, res.RetryAfter)
+				c.ErrorTooManyRequests(w, errRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// rateLimitKey is the login of an authenticated request, the client IP
+// otherwise.
+func rateLimitKey(r *http.Request) string {
+	if token, _, err := jwtauth.FromContext(r.Context()); err == nil && token != nil && token.Subject() != "" {
+		return "user:" + token.Subject()
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
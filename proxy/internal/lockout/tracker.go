@@ -0,0 +1,229 @@
+package lockout
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often stale entries are dropped.
+const pruneInterval = time.Minute
+
+// pendingRetry is the wait of an attempt turned away while the earlier ones
+// are still in flight, they end within a password check.
+const pendingRetry = time.Second
+
+// Policy is when and for how long a key is throttled.
+type Policy struct {
+	// MaxFailures within Window lock the key, 0 never locks it
+	MaxFailures int
+	// Window after the first failure in which failures are counted
+	Window time.Duration
+	// Lockout is how long a locked key stays locked
+	Lockout time.Duration
+	// BaseDelay is the wait after the first failure before the next attempt,
+	// doubled with every further failure, 0 means no delay
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts
+	MaxDelay time.Duration
+}
+
+// Status of a key.
+type Status struct {
+	// Failures counted within the window
+	Failures int
+	// Locked is set while the key is locked out
+	Locked bool
+	// RetryAfter is how long until the next attempt is allowed, zero when it
+	// is allowed right now
+	RetryAfter time.Duration
+}
+
+type entry struct {
+	failures int
+	// pending attempts were reserved and have not failed or been released yet
+	pending     int
+	first       time.Time
+	next        time.Time
+	lockedUntil time.Time
+}
+
+// Tracker counts failed attempts per key, e.g. per login or client IP, and
+// throttles keys with progressive delays and a temporary lockout.
+type Tracker struct {
+	policy Policy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastPrune time.Time
+}
+
+func NewTracker(policy Policy) *Tracker {
+	return &Tracker{policy: policy, now: time.Now, entries: make(map[string]*entry)}
+}
+
+// Check reports whether key may attempt now.
+func (t *Tracker) Check(key string) Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return Status{}
+	}
+	return t.status(e, t.now())
+}
+
+// Reserve is Check for an attempt that is about to start. When key may attempt
+// now the attempt is counted as pending until Fail or Release, and pending
+// attempts count towards MaxFailures: concurrent attempts cannot all pass
+// before the first of them fails.
+func (t *Tracker) Reserve(key string) (status Status, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	e := t.entry(key, now)
+	if status = t.status(e, now); status.RetryAfter > 0 {
+		return status, false
+	}
+	if t.policy.MaxFailures > 0 {
+		// once a lockout is over the attempts go one at a time, the next
+		// failure locks the key again
+		allowed := t.policy.MaxFailures - e.failures
+		if allowed < 1 {
+			allowed = 1
+		}
+		if e.pending >= allowed {
+			status.RetryAfter = pendingRetry
+			return status, false
+		}
+	}
+	e.pending++
+	return status, true
+}
+
+// Release ends a reserved attempt of key that did not fail.
+func (t *Tracker) Release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	if e.pending > 0 {
+		e.pending--
+	}
+	if e.pending == 0 && e.failures == 0 {
+		delete(t.entries, key)
+	}
+}
+
+// Fail records a failed attempt of key, ending its reservation, and returns
+// the new status. locked is set when this failure locked the key.
+func (t *Tracker) Fail(key string) (status Status, locked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if now.Sub(t.lastPrune) >= pruneInterval {
+		t.prune(now)
+		t.lastPrune = now
+	}
+
+	e := t.entry(key, now)
+	if e.pending > 0 {
+		e.pending--
+	}
+	if e.failures == 0 {
+		e.first = now
+	}
+	e.failures++
+	e.next = now.Add(t.delay(e.failures))
+	if t.policy.MaxFailures > 0 && e.failures >= t.policy.MaxFailures && !now.Before(e.lockedUntil) {
+		e.lockedUntil = now.Add(t.policy.Lockout)
+		locked = true
+	}
+	return t.status(e, now), locked
+}
+
+// Reset forgets the failures of key, e.g. after a successful attempt.
+func (t *Tracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.forget(key)
+}
+
+// Unlock forgets the failures of key and reports whether it was locked.
+func (t *Tracker) Unlock(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	locked := ok && t.now().Before(e.lockedUntil)
+	t.forget(key)
+	return locked
+}
+
+// entry returns the entry of key, created when missing and started over when
+// its window and lockout are over. The caller must hold the lock.
+func (t *Tracker) entry(key string, now time.Time) *entry {
+	e, ok := t.entries[key]
+	if !ok {
+		e = &entry{}
+		t.entries[key] = e
+	} else if e.failures > 0 && now.Sub(e.first) > t.policy.Window && !now.Before(e.lockedUntil) {
+		*e = entry{pending: e.pending}
+	}
+	return e
+}
+
+// forget drops the failures of key, its pending attempts are still counted.
+// The caller must hold the lock.
+func (t *Tracker) forget(key string) {
+	e, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	if e.pending == 0 {
+		delete(t.entries, key)
+		return
+	}
+	*e = entry{pending: e.pending}
+}
+
+func (t *Tracker) status(e *entry, now time.Time) Status {
+	status := Status{Failures: e.failures}
+	if now.Before(e.lockedUntil) {
+		status.Locked = true
+		status.RetryAfter = e.lockedUntil.Sub(now)
+	} else if now.Before(e.next) {
+		status.RetryAfter = e.next.Sub(now)
+	}
+	return status
+}
+
+// delay is the wait after the given number of failures.
+func (t *Tracker) delay(failures int) time.Duration {
+	if t.policy.BaseDelay <= 0 {
+		return 0
+	}
+	delay := float64(t.policy.BaseDelay) * math.Pow(2, float64(failures-1))
+	if t.policy.MaxDelay > 0 {
+		delay = math.Min(delay, float64(t.policy.MaxDelay))
+	}
+	return time.Duration(delay)
+}
+
+// prune drops entries whose window and lockout are over, the caller must hold
+// the lock.
+func (t *Tracker) prune(now time.Time) {
+	for key, e := range t.entries {
+		if e.pending == 0 && now.Sub(e.first) > t.policy.Window && !now.Before(e.lockedUntil) && !now.Before(e.next) {
+			delete(t.entries, key)
+		}
+	}
+}
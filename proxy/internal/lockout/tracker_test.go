@@ -0,0 +1,117 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_Fail(t *testing.T) {
+	now := time.Now()
+	tr := NewTracker(Policy{MaxFailures: 3, Window: time.Hour, Lockout: 10 * time.Minute, BaseDelay: time.Second, MaxDelay: 2 * time.Second})
+	tr.now = func() time.Time { return now }
+
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		want       Status
+		wantLocked bool
+	}{
+		{"1", 0, Status{Failures: 1, RetryAfter: time.Second}, false},
+		{"2", time.Second, Status{Failures: 2, RetryAfter: 2 * time.Second}, false},
+		{"3", 2 * time.Second, Status{Failures: 3, Locked: true, RetryAfter: 10 * time.Minute}, true},
+		{"4", time.Minute, Status{Failures: 4, Locked: true, RetryAfter: 9 * time.Minute}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			status, locked := tr.Fail("alice")
+			assert.Equal(t, tt.want, status)
+			assert.Equal(t, tt.wantLocked, locked)
+			assert.Equal(t, tt.want, tr.Check("alice"))
+		})
+	}
+
+	assert.Equal(t, Status{}, tr.Check("bob"))
+
+	// the lockout ends, failures are still counted within the window
+	now = now.Add(9 * time.Minute)
+	assert.Equal(t, Status{Failures: 4}, tr.Check("alice"))
+	_, locked := tr.Fail("alice")
+	assert.True(t, locked)
+
+	// after the window failures start over
+	now = now.Add(time.Hour)
+	status, locked := tr.Fail("alice")
+	assert.False(t, locked)
+	assert.Equal(t, 1, status.Failures)
+}
+
+func TestTracker_ResetUnlock(t *testing.T) {
+	tr := NewTracker(Policy{MaxFailures: 1, Window: time.Hour, Lockout: time.Hour})
+
+	assert.False(t, tr.Unlock("alice"))
+	tr.Fail("alice")
+	assert.True(t, tr.Check("alice").Locked)
+	assert.True(t, tr.Unlock("alice"))
+	assert.Equal(t, Status{}, tr.Check("alice"))
+
+	tr.Fail("bob")
+	tr.Reset("bob")
+	assert.Equal(t, Status{}, tr.Check("bob"))
+}
+
+func TestTracker_Reserve(t *testing.T) {
+	now := time.Now()
+	tr := NewTracker(Policy{MaxFailures: 2, Window: time.Hour, Lockout: time.Hour})
+	tr.now = func() time.Time { return now }
+
+	// pending attempts count towards MaxFailures
+	_, ok := tr.Reserve("alice")
+	assert.True(t, ok)
+	_, ok = tr.Reserve("alice")
+	assert.True(t, ok)
+	status, ok := tr.Reserve("alice")
+	assert.False(t, ok)
+	assert.Equal(t, Status{RetryAfter: pendingRetry}, status)
+
+	// a released attempt frees its slot, a failed one keeps it as a failure
+	tr.Release("alice")
+	_, ok = tr.Reserve("alice")
+	assert.True(t, ok)
+	tr.Fail("alice")
+	_, ok = tr.Reserve("alice")
+	assert.False(t, ok)
+	tr.Fail("alice")
+	status, ok = tr.Reserve("alice")
+	assert.False(t, ok)
+	assert.True(t, status.Locked)
+
+	// once the lockout is over the attempts go one at a time
+	now = now.Add(time.Hour)
+	_, ok = tr.Reserve("alice")
+	assert.True(t, ok)
+	_, ok = tr.Reserve("alice")
+	assert.False(t, ok)
+
+	// attempts that did not fail leave nothing behind
+	_, _ = tr.Reserve("bob")
+	tr.Release("bob")
+	assert.NotContains(t, tr.entries, "bob")
+}
+
+func TestTracker_Prune(t *testing.T) {
+	now := time.Now()
+	tr := NewTracker(Policy{MaxFailures: 2, Window: time.Minute, Lockout: time.Hour})
+	tr.now = func() time.Time { return now }
+
+	tr.Fail("alice")
+	tr.Fail("bob")
+	tr.Fail("bob")
+
+	now = now.Add(2 * time.Minute)
+	tr.Fail("carol")
+	assert.Len(t, tr.entries, 2)
+	assert.NotContains(t, tr.entries, "alice")
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled with rate tokens per second up to burst
+// tokens.
+type Bucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket creates a full bucket. A rate of 0 or less means no limit.
+func NewBucket(rate float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		now:    time.Now,
+		tokens: float64(burst),
+	}
+}
+
+// Allow takes a token if one is available right now.
+func (b *Bucket) Allow() bool {
+	_, ok := b.Reserve(0)
+	return ok
+}
+
+// Reserve takes a token that becomes available within maxWait and returns
+// how long the caller has to wait for it. When the wait would be longer
+// nothing is taken and ok is false, wait is then the time until a token is
+// available.
+func (b *Bucket) Reserve(maxWait time.Duration) (wait time.Duration, ok bool) {
+	if b.rate <= 0 {
+		return 0, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+
+	wait = time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second)))
+	if wait > maxWait {
+		return wait, false
+	}
+	// tokens go negative, so later callers queue up behind this one
+	b.tokens--
+	return wait, true
+}
+
+// take takes a token if one is available right now and returns the tokens
+// left, below 1 when nothing was taken.
+func (b *Bucket) take() (tokens float64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	}
+	return b.tokens, ok
+}
+
+// full reports whether the bucket has refilled completely by now.
+func (b *Bucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// Remaining is the number of whole tokens available right now.
+func (b *Bucket) Remaining() int {
+	if b.rate <= 0 {
+		return int(b.burst)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < 0 {
+		return 0
+	}
+	return int(b.tokens)
+}
+
+// refill adds the tokens accrued since the last call, the caller must hold the
+// lock.
+func (b *Bucket) refill() {
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
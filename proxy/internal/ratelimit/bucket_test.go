@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_Allow(t *testing.T) {
+	now := time.Now()
+	b := NewBucket(2, 3)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow(), i)
+	}
+	assert.False(t, b.Allow())
+	assert.Equal(t, 0, b.Remaining())
+
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, 1, b.Remaining())
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	// never more than burst
+	now = now.Add(time.Hour)
+	assert.Equal(t, 3, b.Remaining())
+}
+
+func TestBucket_Reserve(t *testing.T) {
+	now := time.Now()
+	b := NewBucket(10, 1)
+	b.now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		maxWait time.Duration
+		want    time.Duration
+		wantOK  bool
+	}{
+		{"1", 0, 0, true},
+		{"2", time.Second, 100 * time.Millisecond, true},
+		{"3", time.Second, 200 * time.Millisecond, true},
+		{"4", 100 * time.Millisecond, 300 * time.Millisecond, false},
+		{"5", 300 * time.Millisecond, 300 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := b.Reserve(tt.maxWait)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.InDelta(t, tt.want, wait, float64(time.Millisecond))
+		})
+	}
+}
+
+func TestBucket_Unlimited(t *testing.T) {
+	b := NewBucket(0, 0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, b.Allow())
+	}
+	assert.Equal(t, 1, b.Remaining())
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often buckets of idle keys are dropped.
+const pruneInterval = time.Minute
+
+// Result is the outcome of Limiter.Allow.
+type Result struct {
+	Allowed bool
+	// Limit is the burst size of the bucket
+	Limit int
+	// Remaining is the number of requests left right now
+	Remaining int
+	// RetryAfter is when the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+	// Reset is when the bucket is full again
+	Reset time.Duration
+}
+
+// Limiter keeps a token bucket per key, e.g. per user or client IP.
+type Limiter struct {
+	rate  float64
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastPrune time.Time
+}
+
+// NewLimiter allows rate requests per second per key with bursts of up to
+// burst requests. A rate of 0 or less means no limit.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		now:     time.Now,
+		buckets: make(map[string]*Bucket),
+	}
+}
+
+// Allow takes a token from the bucket of key if one is available.
+func (l *Limiter) Allow(key string) Result {
+	if l.rate <= 0 {
+		return Result{Allowed: true, Limit: l.burst, Remaining: l.burst}
+	}
+	tokens, ok := l.bucket(key).take()
+	res := Result{
+		Allowed:   ok,
+		Limit:     l.burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     l.duration(float64(l.burst) - tokens),
+	}
+	if !ok {
+		res.RetryAfter = l.duration(1 - tokens)
+	}
+	return res
+}
+
+// duration is how long the bucket takes to refill tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.rate * float64(time.Second)))
+}
+
+func (l *Limiter) bucket(key string) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) >= pruneInterval {
+		l.prune(now)
+		l.lastPrune = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = NewBucket(l.rate, l.burst)
+		b.now = l.now
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// prune drops buckets that have refilled completely, a new full bucket is
+// the same. The caller must hold the lock.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		key     string
+		advance time.Duration
+		want    Result
+	}{
+		{"1", "alice", 0, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+		{"2", "alice", 0, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+		{"3", "alice", 0, Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: time.Second, Reset: 2 * time.Second}},
+		{"4", "bob", 0, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+		{"5", "alice", 500 * time.Millisecond, Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: 1500 * time.Millisecond}},
+		{"6", "alice", 500 * time.Millisecond, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			assert.Equal(t, tt.want, l.Allow(tt.key))
+		})
+	}
+}
+
+func TestLimiter_Prune(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return now }
+
+	l.Allow("alice")
+	now = now.Add(pruneInterval - time.Second)
+	l.Allow("bob")
+	l.Allow("bob")
+	assert.Len(t, l.buckets, 2)
+
+	// alice's bucket has refilled, bob's has not
+	now = now.Add(time.Second)
+	l.Allow("carol")
+	assert.Len(t, l.buckets, 2)
+	assert.Contains(t, l.buckets, "bob")
+	assert.Contains(t, l.buckets, "carol")
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := NewLimiter(0, 5)
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.Allow("alice").Allowed)
+	}
+}
@@ -16,8 +16,16 @@ type Responder interface {
 	ErrorUserConflict(w http.ResponseWriter)
 	ErrorNotAllowed(w http.ResponseWriter)
 	ErrorBadRequest(w http.ResponseWriter, err error)
+	ErrorValidation(w http.ResponseWriter, errs []FieldError)
 	ErrorForbidden(w http.ResponseWriter)
+	ErrorUnauthorized(w http.ResponseWriter)
+	ErrorNotFound(w http.ResponseWriter)
+	ErrorGatewayTimeout(w http.ResponseWriter, err error)
+	ErrorServiceUnavailable(w http.ResponseWriter, err error)
+	ErrorTooManyRequests(w http.ResponseWriter, err error)
+	ErrorLocked(w http.ResponseWriter, err error)
 	ErrorInternal(w http.ResponseWriter, err error)
+	LogItemError(i, status int, err error)
 }
 
 type Respond struct {
@@ -51,6 +59,17 @@ func (r *Respond) ErrorBadRequest(w http.ResponseWriter, err1 error) {
 	//}
 }
 
+// ErrorValidation responds 400 listing every rule the request failed.
+func (r *Respond) ErrorValidation(w http.ResponseWriter, errs []FieldError) {
+	r.log.Info("http response validation failed", zap.Any("errors", errs))
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = r.Encode(w, ValidationErrorResponse{
+		Message: "400 validation failed",
+		Errors:  errs,
+	})
+}
+
 func (r *Respond) ErrorForbidden(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
 	w.WriteHeader(http.StatusForbidden)
@@ -64,6 +83,58 @@ func (r *Respond) ErrorForbidden(w http.ResponseWriter) {
 	//}
 }
 
+func (r *Respond) ErrorUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = r.Encode(w, ErrorResponse{
+		Message: "401 Unauthorized",
+	})
+}
+
+func (r *Respond) ErrorNotFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	_ = r.Encode(w, ErrorResponse{
+		Message: "404 Not found",
+	})
+}
+
+func (r *Respond) ErrorGatewayTimeout(w http.ResponseWriter, err error) {
+	r.log.Info("http response gateway timeout", zap.Error(err))
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	_ = r.Encode(w, ErrorResponse{
+		Message: "504 Gateway Timeout",
+	})
+}
+
+func (r *Respond) ErrorServiceUnavailable(w http.ResponseWriter, err error) {
+	r.log.Info("http response service unavailable", zap.Error(err))
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = r.Encode(w, ErrorResponse{
+		Message: "503 Service Unavailable",
+	})
+}
+
+func (r *Respond) ErrorTooManyRequests(w http.ResponseWriter, err error) {
+	r.log.Info("http response too many requests", zap.Error(err))
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = r.Encode(w, ErrorResponse{
+		Message: "429 Too Many Requests",
+	})
+}
+
+func (r *Respond) ErrorLocked(w http.ResponseWriter, err error) {
+	r.log.Info("http response locked", zap.Error(err))
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusLocked)
+	_ = r.Encode(w, ErrorResponse{
+		Message: "423 Account is temporarily locked",
+	})
+}
+
 func (r *Respond) ErrorInternal(w http.ResponseWriter, err error) {
 	r.log.Info("http response internal server error", zap.Error(err))
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
@@ -78,6 +149,12 @@ func (r *Respond) ErrorInternal(w http.ResponseWriter, err error) {
 	//}
 }
 
+// LogItemError logs the item i of a batch failed with a server error. The
+// item is answered within the batch response, nothing is written.
+func (r *Respond) LogItemError(i, status int, err error) {
+	r.log.Error("batch item failed", zap.Int("item", i), zap.Int("status", status), zap.Error(err))
+}
+
 func (r *Respond) ErrorNotAllowed(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
 	w.WriteHeader(http.StatusMethodNotAllowed)
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestRespond_OutputJSON(t *testing.T) {
@@ -103,6 +104,154 @@ func TestRespond_ErrorInternal(t *testing.T) {
 	}
 }
 
+func TestRespond_ErrorGatewayTimeout(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := NewResponder(decoder, logger)
+
+	type args struct {
+		w   *httptest.ResponseRecorder
+		err error
+	}
+	tests := []struct {
+		name string
+		resp Responder
+		args args
+		want int
+	}{
+		{"1", respond, args{httptest.NewRecorder(), errors.New("test")}, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.ErrorGatewayTimeout(tt.args.w, tt.args.err)
+			assert.Equal(t, tt.args.w.Code, tt.want)
+		})
+	}
+}
+
+func TestRespond_ErrorServiceUnavailable(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := NewResponder(decoder, logger)
+
+	type args struct {
+		w   *httptest.ResponseRecorder
+		err error
+	}
+	tests := []struct {
+		name string
+		resp Responder
+		args args
+		want int
+	}{
+		{"1", respond, args{httptest.NewRecorder(), errors.New("test")}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.ErrorServiceUnavailable(tt.args.w, tt.args.err)
+			assert.Equal(t, tt.args.w.Code, tt.want)
+		})
+	}
+}
+
+func TestRespond_ErrorTooManyRequests(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := NewResponder(decoder, logger)
+
+	type args struct {
+		w   *httptest.ResponseRecorder
+		err error
+	}
+	tests := []struct {
+		name string
+		resp Responder
+		args args
+		want int
+	}{
+		{"1", respond, args{httptest.NewRecorder(), errors.New("test")}, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.ErrorTooManyRequests(tt.args.w, tt.args.err)
+			assert.Equal(t, tt.args.w.Code, tt.want)
+		})
+	}
+}
+
+func TestRespond_ErrorLocked(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := NewResponder(decoder, logger)
+
+	type args struct {
+		w   *httptest.ResponseRecorder
+		err error
+	}
+	tests := []struct {
+		name string
+		resp Responder
+		args args
+		want int
+	}{
+		{"1", respond, args{httptest.NewRecorder(), errors.New("test")}, http.StatusLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.ErrorLocked(tt.args.w, tt.args.err)
+			assert.Equal(t, tt.args.w.Code, tt.want)
+		})
+	}
+}
+
+func TestRespond_ErrorValidation(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := NewResponder(decoder, logger)
+
+	type args struct {
+		w    *httptest.ResponseRecorder
+		errs []FieldError
+	}
+	tests := []struct {
+		name     string
+		resp     Responder
+		args     args
+		want     int
+		wantBody string
+	}{
+		{"1", respond, args{httptest.NewRecorder(), []FieldError{{Field: "login", Rule: "required", Message: "must not be empty"}}}, http.StatusBadRequest,
+			`{"error":"400 validation failed","errors":[{"field":"login","rule":"required","message":"must not be empty"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.ErrorValidation(tt.args.w, tt.args.errs)
+			assert.Equal(t, tt.args.w.Code, tt.want)
+			assert.JSONEq(t, tt.wantBody, tt.args.w.Body.String())
+		})
+	}
+}
+
 func TestRespond_ErrorUserNotFound(t *testing.T) {
 	logger := zap.New(zapcore.NewCore(
 		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
@@ -214,3 +363,69 @@ func TestRespond_ErrorForbidden(t *testing.T) {
 		})
 	}
 }
+
+func TestRespond_ErrorNotFound(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := NewResponder(decoder, logger)
+
+	type args struct {
+		w *httptest.ResponseRecorder
+	}
+	tests := []struct {
+		name string
+		resp Responder
+		args args
+		want int
+	}{
+		{"1", respond, args{httptest.NewRecorder()}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.ErrorNotFound(tt.args.w)
+			assert.Equal(t, tt.args.w.Code, tt.want)
+		})
+	}
+}
+
+func TestRespond_ErrorUnauthorized(t *testing.T) {
+	logger := zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stdout),
+		zap.DebugLevel,
+	))
+	decoder := godecoder.NewDecoder()
+	respond := NewResponder(decoder, logger)
+
+	type args struct {
+		w *httptest.ResponseRecorder
+	}
+	tests := []struct {
+		name string
+		resp Responder
+		args args
+		want int
+	}{
+		{"1", respond, args{httptest.NewRecorder()}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.ErrorUnauthorized(tt.args.w)
+			assert.Equal(t, tt.args.w.Code, tt.want)
+		})
+	}
+}
+
+func TestRespond_LogItemError(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	respond := NewResponder(godecoder.NewDecoder(), zap.New(core))
+
+	respond.LogItemError(3, http.StatusInternalServerError, errors.New("test"))
+	entries := logs.FilterMessage("batch item failed").All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, int64(3), entries[0].ContextMap()["item"])
+}
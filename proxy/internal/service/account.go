@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"test/proxy/internal/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ChangePassword replaces the password of login after checking the current
+// one. Every token issued before is revoked, the caller gets a new token
+// pair. A new password failing the policy is reported as *ValidationError.
+func (g *GeoService) ChangePassword(login, current, passw, ip string) (*Tokens, error) {
+	user, err := g.checkPassword(login, current)
+	if err != nil {
+		return nil, err
+	}
+	if errs := g.passwords.ValidatePassword("new_password", login, passw); len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	pass, err := g.passwords.Hash(passw)
+	if err != nil {
+		return nil, fmt.Errorf("error change password of %s: %w", login, err)
+	}
+	epoch, err := newTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("error change password of %s: %w", login, err)
+	}
+	if err := g.users.SetPassword(login, pass, epoch); err != nil {
+		return nil, fmt.Errorf("error change password of %s: %w", login, err)
+	}
+	user.Password = pass
+	user.TokenEpoch = epoch
+
+	return g.issueTokens(user)
+}
+
+// DeleteAccount removes login and its API keys after checking the password.
+// Tokens of a deleted user are revoked, as their user no longer exists.
+func (g *GeoService) DeleteAccount(login, passw, ip string) error {
+	if _, err := g.checkPassword(login, passw); err != nil {
+		return err
+	}
+	return g.deleteUser(login)
+}
+
+func (g *GeoService) deleteUser(login string) error {
+	keys, err := g.apiKeys.List(login)
+	if err != nil {
+		return fmt.Errorf("error delete user %s: %v", login, err)
+	}
+	for _, key := range keys {
+		if err := g.apiKeys.Delete(login, key.ID); err != nil && !errors.Is(err, storage.ErrAPIKeyNotFound) {
+			return fmt.Errorf("error delete user %s: %v", login, err)
+		}
+	}
+
+	if err := g.users.Delete(login); err != nil {
+		return fmt.Errorf("error delete user %s: %w", login, err)
+	}
+	return nil
+}
+
+func (g *GeoService) checkPassword(login, passw string) (storage.User, error) {
+	user, err := g.users.Get(login)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(g.passwords.dummyHash(), []byte(passw))
+		return storage.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return storage.User{}, fmt.Errorf("error get user %s: %w", login, err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passw)); err != nil {
+		return storage.User{}, ErrInvalidCredentials
+	}
+	// only told once the password is right, not to reveal the account
+	if user.Disabled {
+		return storage.User{}, ErrAccountDisabled
+	}
+	return user, nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoService_ChangePassword(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	tokens, _ := serv.Register("User1", "qwerty")
+	other, _ := serv.Register("User2", "qwerty")
+
+	_, err := serv.ChangePassword("User1", "wrong", "asdfgh", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	var invalid *ValidationError
+	_, err = serv.ChangePassword("User1", "qwerty", "", "192.0.2.1")
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "new_password", invalid.Errors[0].Field)
+
+	changed, err := serv.ChangePassword("User1", "qwerty", "asdfgh", "192.0.2.1")
+	assert.NoError(t, err)
+
+	// every token issued before the change is revoked, the new ones are not
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"1", tokens.AccessToken, true},
+		{"2", tokens.RefreshToken, true},
+		{"3", changed.AccessToken, false},
+		{"4", other.AccessToken, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := testTokenAuth.Decode(tt.token)
+			assert.NoError(t, err)
+			revoked, err := serv.IsRevoked(tok)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, revoked)
+		})
+	}
+	_, err = serv.Refresh(tokens.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = serv.Login("User1", "qwerty", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = serv.Login("User1", "asdfgh", "192.0.2.1")
+	assert.NoError(t, err)
+}
+
+func TestGeoService_DeleteAccount(t *testing.T) {
+	apiKeys := storage.NewMemoryAPIKeyRepository()
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), apiKeys, testTokenAuth, testPasswords)
+	tokens, _ := serv.Register("User1", "qwerty")
+	issued, err := serv.CreateAPIKey("User1", "import", nil, 0)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, serv.DeleteAccount("User1", "wrong", "192.0.2.1"), ErrInvalidCredentials)
+	assert.True(t, serv.IsUserExist("User1"))
+
+	assert.NoError(t, serv.DeleteAccount("User1", "qwerty", "192.0.2.1"))
+	assert.False(t, serv.IsUserExist("User1"))
+	keys, _ := apiKeys.List("User1")
+	assert.Empty(t, keys)
+	_, err = serv.AuthenticateAPIKey(issued.Key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	// tokens of the deleted account stay revoked after the login is taken again
+	_, err = serv.Register("User1", "qwerty")
+	assert.NoError(t, err)
+	access, _ := testTokenAuth.Decode(tokens.AccessToken)
+	revoked, err := serv.IsRevoked(access)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	_, err = serv.Refresh(tokens.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
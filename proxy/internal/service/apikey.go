@@ -0,0 +1,176 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"test/proxy/internal/storage"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const (
+	// ScopesClaim limits what a token authenticated by an API key may call.
+	// Tokens without it are interactive sessions and may call everything.
+	ScopesClaim = "scopes"
+
+	ScopeAddressSearch  = "address:search"
+	ScopeAddressGeocode = "address:geocode"
+	// ScopeKeys allows managing API keys, it is never granted by default
+	ScopeKeys = "keys"
+	// ScopeAccount allows changing the password and deleting the account,
+	// it is never granted by default
+	ScopeAccount = "account"
+	// ScopeHistory allows reading and clearing the search history, it is
+	// never granted by default
+	ScopeHistory = "history"
+	// ScopePlaces allows managing saved places, it is never granted by
+	// default
+	ScopePlaces = "places"
+	// ScopeAdmin allows the admin endpoints to keys of admins, it is never
+	// granted by default
+	ScopeAdmin = "admin"
+
+	apiKeyPrefix = "gk_"
+	// apiKeyAttempts is how often a key is drawn again when its ID is taken
+	apiKeyAttempts = 3
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrInvalidScope  = errors.New("unknown api key scope")
+
+	// DefaultAPIKeyScopes are granted to keys issued without explicit scopes
+	DefaultAPIKeyScopes = []string{ScopeAddressSearch, ScopeAddressGeocode}
+
+	knownScopes = map[string]bool{ScopeAddressSearch: true, ScopeAddressGeocode: true, ScopeKeys: true, ScopeAccount: true, ScopeHistory: true, ScopePlaces: true, ScopeAdmin: true}
+)
+
+// IssuedAPIKey is returned once on creation, it is the only time the
+// plaintext key is available.
+type IssuedAPIKey struct {
+	storage.APIKey
+	Key string
+}
+
+// CreateAPIKey issues a new key for the user. A zero ttl creates a key that
+// never expires.
+func (g *GeoService) CreateAPIKey(login, name string, scopes []string, ttl time.Duration) (*IssuedAPIKey, error) {
+	if len(scopes) == 0 {
+		scopes = DefaultAPIKeyScopes
+	}
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	// the short IDs of two keys may collide, a new one is drawn then
+	for attempt := 1; ; attempt++ {
+		id, err := newTokenID()
+		if err != nil {
+			return nil, err
+		}
+		secret, err := newTokenID()
+		if err != nil {
+			return nil, err
+		}
+		plain := apiKeyPrefix + id[:8] + secret
+
+		now := time.Now()
+		key := storage.APIKey{
+			ID:        id[:8],
+			Login:     login,
+			Hash:      hashAPIKey(plain),
+			Name:      name,
+			Scopes:    scopes,
+			CreatedAt: now,
+		}
+		if ttl > 0 {
+			key.ExpiresAt = now.Add(ttl)
+		}
+		err = g.apiKeys.Create(key)
+		if errors.Is(err, storage.ErrAPIKeyExists) && attempt < apiKeyAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error create api key: %v", err)
+		}
+		return &IssuedAPIKey{APIKey: key, Key: plain}, nil
+	}
+}
+
+func (g *GeoService) ListAPIKeys(login string) ([]storage.APIKey, error) {
+	keys, err := g.apiKeys.List(login)
+	if err != nil {
+		return nil, fmt.Errorf("error list api keys: %v", err)
+	}
+	return keys, nil
+}
+
+func (g *GeoService) DeleteAPIKey(login, id string) error {
+	if err := g.apiKeys.Delete(login, id); err != nil {
+		return fmt.Errorf("error delete api key %s: %w", id, err)
+	}
+	return nil
+}
+
+// AuthenticateAPIKey checks the key and returns an unsigned access token for
+// its owner, so API key callers pass the same checks as JWT callers.
+func (g *GeoService) AuthenticateAPIKey(plain string) (jwt.Token, error) {
+	key, err := g.apiKeys.GetByHash(hashAPIKey(plain))
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error get api key: %v", err)
+	}
+
+	now := time.Now()
+	if key.Expired(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	user, err := g.users.Get(key.Login)
+	if err != nil || user.Disabled {
+		return nil, ErrInvalidAPIKey
+	}
+
+	exp := now.Add(g.tokenAuth.AccessTTL())
+	if !key.ExpiresAt.IsZero() && key.ExpiresAt.Before(exp) {
+		exp = key.ExpiresAt
+	}
+
+	token := jwt.New()
+	for k, v := range map[string]interface{}{
+		jwt.SubjectKey:    user.Login,
+		jwt.IssuedAtKey:   now.Unix(),
+		jwt.ExpirationKey: exp.Unix(),
+		jwt.JwtIDKey:      "apikey-" + key.ID,
+		TokenTypeClaim:    AccessTokenType,
+		RolesClaim:        user.Roles,
+		EpochClaim:        user.TokenEpoch,
+		ScopesClaim:       key.Scopes,
+	} {
+		if err := token.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+// TokenScopes returns the scopes of the token and false for interactive
+// sessions, which are not limited by scopes.
+func TokenScopes(token jwt.Token) ([]string, bool) {
+	if _, ok := token.Get(ScopesClaim); !ok {
+		return nil, false
+	}
+	return claimStrings(token, ScopesClaim), true
+}
+
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
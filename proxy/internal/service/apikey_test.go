@@ -0,0 +1,125 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"test/proxy/internal/storage"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoService_CreateAPIKey(t *testing.T) {
+	apiKeys := storage.NewMemoryAPIKeyRepository()
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), apiKeys, testTokenAuth, testPasswords)
+
+	tests := []struct {
+		name       string
+		scopes     []string
+		ttl        time.Duration
+		wantScopes []string
+		wantErr    error
+	}{
+		{"1", nil, 0, DefaultAPIKeyScopes, nil},
+		{"2", []string{ScopeAddressSearch}, time.Hour, []string{ScopeAddressSearch}, nil},
+		{"3", []string{"root"}, 0, nil, ErrInvalidScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := serv.CreateAPIKey("User1", "job", tt.scopes, tt.ttl)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			assert.True(t, strings.HasPrefix(got.Key, apiKeyPrefix+got.ID))
+			assert.Equal(t, tt.wantScopes, got.Scopes)
+			assert.Equal(t, tt.ttl == 0, got.ExpiresAt.IsZero())
+
+			stored, err := apiKeys.GetByHash(hashAPIKey(got.Key))
+			assert.NoError(t, err)
+			assert.NotContains(t, stored.Hash, got.Key)
+		})
+	}
+}
+
+// collidingAPIKeys answers ErrAPIKeyExists to the first collisions creations
+type collidingAPIKeys struct {
+	*storage.MemoryAPIKeyRepository
+	collisions int
+}
+
+func (c *collidingAPIKeys) Create(key storage.APIKey) error {
+	if c.collisions > 0 {
+		c.collisions--
+		return storage.ErrAPIKeyExists
+	}
+	return c.MemoryAPIKeyRepository.Create(key)
+}
+
+func TestGeoService_CreateAPIKeyCollision(t *testing.T) {
+	apiKeys := &collidingAPIKeys{MemoryAPIKeyRepository: storage.NewMemoryAPIKeyRepository(), collisions: apiKeyAttempts - 1}
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), apiKeys, testTokenAuth, testPasswords)
+
+	// a taken ID is drawn again
+	issued, err := serv.CreateAPIKey("User1", "", nil, 0)
+	assert.NoError(t, err)
+	keys, _ := apiKeys.List("User1")
+	assert.Equal(t, []storage.APIKey{issued.APIKey}, keys)
+
+	// but not forever
+	apiKeys.collisions = apiKeyAttempts
+	_, err = serv.CreateAPIKey("User1", "", nil, 0)
+	assert.Error(t, err)
+}
+
+func TestGeoService_AuthenticateAPIKey(t *testing.T) {
+	apiKeys := storage.NewMemoryAPIKeyRepository()
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), apiKeys, testTokenAuth, testPasswords)
+	serv.Register("User1", "qwerty")
+
+	valid, _ := serv.CreateAPIKey("User1", "", []string{ScopeAddressSearch}, time.Hour)
+	orphan, _ := serv.CreateAPIKey("User2", "", nil, 0)
+	_ = apiKeys.Create(storage.APIKey{ID: "expired", Login: "User1", Hash: hashAPIKey("gk_expired"), ExpiresAt: time.Now().Add(-time.Second)})
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{"1", valid.Key, nil},
+		{"2", "gk_expired", ErrInvalidAPIKey},
+		{"3", orphan.Key, ErrInvalidAPIKey},
+		{"4", "gk_unknown", ErrInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := serv.AuthenticateAPIKey(tt.key)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			assert.NoError(t, jwt.Validate(token, jwt.WithRequiredClaim(jwt.JwtIDKey)))
+			assert.Equal(t, "User1", token.Subject())
+			assert.Equal(t, []string{storage.RoleUser}, TokenRoles(token))
+			scopes, limited := TokenScopes(token)
+			assert.True(t, limited)
+			assert.Equal(t, []string{ScopeAddressSearch}, scopes)
+		})
+	}
+
+	assert.NoError(t, serv.DeleteAPIKey("User1", valid.ID))
+	_, err := serv.AuthenticateAPIKey(valid.Key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	assert.ErrorIs(t, serv.DeleteAPIKey("User1", valid.ID), storage.ErrAPIKeyNotFound)
+}
+
+func TestTokenScopes(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	tokens, _ := serv.Register("User1", "qwerty")
+	token, _ := testTokenAuth.Decode(tokens.AccessToken)
+
+	_, limited := TokenScopes(token)
+	assert.False(t, limited)
+}
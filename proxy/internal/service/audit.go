@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"test/proxy/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// AuditGeoService decorates a GeoServicer with an audit trail. A failure to
+// record an event is logged, it does not fail the action it describes.
+type AuditGeoService struct {
+	GeoServicer
+	log    storage.AuditLog
+	logger *zap.Logger
+}
+
+func NewAuditGeoService(next GeoServicer, log storage.AuditLog, logger *zap.Logger) GeoServicer {
+	return &AuditGeoService{GeoServicer: next, log: log, logger: logger}
+}
+
+func (a *AuditGeoService) Unwrap() GeoServicer {
+	return a.GeoServicer
+}
+
+func (a *AuditGeoService) Audit(event storage.AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if err := a.log.Append(event); err != nil {
+		a.logger.Error("audit event lost",
+			zap.Error(err),
+			zap.String("actor", event.Actor),
+			zap.String("action", event.Action),
+			zap.String("target", event.Target),
+		)
+	}
+}
+
+func (a *AuditGeoService) AuditEvents(filter storage.AuditFilter) ([]storage.AuditEvent, error) {
+	events, err := a.log.Query(filter)
+	if err != nil {
+		return nil, fmt.Errorf("error query audit log: %v", err)
+	}
+	return events, nil
+}
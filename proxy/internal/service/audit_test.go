@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type failingAuditLog struct{}
+
+func (failingAuditLog) Append(event storage.AuditEvent) error {
+	return errors.New("disk full")
+}
+
+func (failingAuditLog) Query(filter storage.AuditFilter) ([]storage.AuditEvent, error) {
+	return nil, errors.New("disk full")
+}
+
+func TestAuditGeoService_Audit(t *testing.T) {
+	log := storage.NewMemoryAuditLog()
+	serv := NewAuditGeoService(NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), log, zap.NewNop()).(*AuditGeoService)
+
+	at := time.Unix(1700000000, 0)
+	serv.Audit(storage.AuditEvent{Time: at, Actor: "Admin", Action: "GET /api/admin/users", Status: 200})
+	serv.Audit(storage.AuditEvent{Actor: "Admin", Action: "POST /api/admin/users/{login}/disable", Target: "User1", Status: 204})
+
+	events := log.Events()
+	assert.Len(t, events, 2)
+	assert.Equal(t, at, events[0].Time)
+	assert.False(t, events[1].Time.IsZero())
+	assert.Equal(t, "User1", events[1].Target)
+}
+
+func TestAuditGeoService_AuditFailure(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	serv := NewAuditGeoService(NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), failingAuditLog{}, zap.New(core)).(*AuditGeoService)
+
+	serv.Audit(storage.AuditEvent{Actor: "Admin", Action: "DELETE /api/admin/users/{login}", Target: "User1"})
+
+	lost := logs.FilterMessage("audit event lost").All()
+	assert.Len(t, lost, 1)
+	assert.Equal(t, "User1", lost[0].ContextMap()["target"])
+}
+
+func TestAuditGeoService_AuditEvents(t *testing.T) {
+	log := storage.NewMemoryAuditLog()
+	serv := NewAuditGeoService(NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), log, zap.NewNop()).(*AuditGeoService)
+
+	serv.Audit(storage.AuditEvent{Actor: "User1", Action: "login", Status: 200})
+	serv.Audit(storage.AuditEvent{Actor: "User2", Action: "login", Status: 401})
+	serv.Audit(storage.AuditEvent{Actor: "User1", Action: "address.search", Query: "Москва", Status: 200})
+
+	events, err := serv.AuditEvents(storage.AuditFilter{Actor: "User1"})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "address.search", events[0].Action)
+
+	serv = NewAuditGeoService(NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), failingAuditLog{}, zap.NewNop()).(*AuditGeoService)
+	_, err = serv.AuditEvents(storage.AuditFilter{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+)
+
+var ErrBatchTooLarge = errors.New("batch is too large")
+
+// SearchResult is the outcome of one query of a batch, either Response or
+// Err is set.
+type SearchResult struct {
+	Response *responder.SearchResponse
+	Err      error
+}
+
+// GeocodeResult is the outcome of one point of a batch, either Response or
+// Err is set.
+type GeocodeResult struct {
+	Response *responder.GeocodeResponse
+	Err      error
+}
+
+type GeocodePoint struct {
+	Lat string
+	Lon string
+}
+
+// SearchBatch looks the queries up one at a time, they are fanned out by
+// BatchGeoService.
+func (g *GeoService) SearchBatch(ctx context.Context, queries []string) ([]SearchResult, error) {
+	return searchBatch(ctx, g, queries, 1), nil
+}
+
+// GeocodeBatch looks the points up one at a time, they are fanned out by
+// BatchGeoService.
+func (g *GeoService) GeocodeBatch(ctx context.Context, points []GeocodePoint) ([]GeocodeResult, error) {
+	return geocodeBatch(ctx, g, points, 1), nil
+}
+
+// BatchGeoService decorates a GeoServicer with batches looked up by at most
+// Concurrency calls at once. Every item goes through the wrapped service, so
+// it is cached, coalesced and guarded like a single lookup.
+type BatchGeoService struct {
+	GeoServicer
+	maxSize     int
+	concurrency int
+}
+
+func NewBatchGeoService(next GeoServicer, conf config.Batch) GeoServicer {
+	concurrency := conf.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &BatchGeoService{GeoServicer: next, maxSize: conf.MaxSize, concurrency: concurrency}
+}
+
+func (b *BatchGeoService) Unwrap() GeoServicer {
+	return b.GeoServicer
+}
+
+// SearchBatch returns the results in the order of the queries. A batch over
+// the maximum size is rejected with ErrBatchTooLarge.
+func (b *BatchGeoService) SearchBatch(ctx context.Context, queries []string) ([]SearchResult, error) {
+	if err := b.checkSize(len(queries)); err != nil {
+		return nil, err
+	}
+	return searchBatch(ctx, b.GeoServicer, queries, b.concurrency), nil
+}
+
+// GeocodeBatch returns the results in the order of the points. A batch over
+// the maximum size is rejected with ErrBatchTooLarge.
+func (b *BatchGeoService) GeocodeBatch(ctx context.Context, points []GeocodePoint) ([]GeocodeResult, error) {
+	if err := b.checkSize(len(points)); err != nil {
+		return nil, err
+	}
+	return geocodeBatch(ctx, b.GeoServicer, points, b.concurrency), nil
+}
+
+// GetGeoResp rejects invalid coordinates with ErrInvalidCoordinates before
+// they reach a provider.
+func (b *BatchGeoService) GetGeoResp(ctx context.Context, lat, lon string) (*responder.GeocodeResponse, error) {
+	if _, _, err := parseCoordinates(lat, lon); err != nil {
+		return nil, err
+	}
+	return b.GeoServicer.GetGeoResp(ctx, lat, lon)
+}
+
+func (b *BatchGeoService) checkSize(n int) error {
+	if b.maxSize > 0 && n > b.maxSize {
+		return fmt.Errorf("%w: %d items, at most %d are allowed", ErrBatchTooLarge, n, b.maxSize)
+	}
+	return nil
+}
+
+func searchBatch(ctx context.Context, serv GeoServicer, queries []string, concurrency int) []SearchResult {
+	results := make([]SearchResult, len(queries))
+	fanOut(len(queries), concurrency, func(i int) {
+		results[i].Response, results[i].Err = serv.GetSearchResp(ctx, queries[i])
+	})
+	return results
+}
+
+func geocodeBatch(ctx context.Context, serv GeoServicer, points []GeocodePoint, concurrency int) []GeocodeResult {
+	results := make([]GeocodeResult, len(points))
+	fanOut(len(points), concurrency, func(i int) {
+		if _, _, err := parseCoordinates(points[i].Lat, points[i].Lon); err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].Response, results[i].Err = serv.GetGeoResp(ctx, points[i].Lat, points[i].Lon)
+	})
+	return results
+}
+
+// fanOut calls fn for every index below n, at most concurrency calls run at
+// once. It returns when every call has returned.
+func fanOut(n, concurrency int, fn func(i int)) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echoProvider answers with the query itself and fails queries starting with
+// "fail", it counts the calls running at once.
+type echoProvider struct {
+	running int32
+	peak    int32
+}
+
+func (p *echoProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	running := atomic.AddInt32(&p.running, 1)
+	defer atomic.AddInt32(&p.running, -1)
+	for {
+		peak := atomic.LoadInt32(&p.peak)
+		if running <= peak || atomic.CompareAndSwapInt32(&p.peak, peak, running) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if strings.HasPrefix(query, "fail") {
+		return nil, errors.New("provider error")
+	}
+	return []*responder.Address{{Address: query}}, nil
+}
+
+func (p *echoProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	return p.Search(ctx, lat+","+lon)
+}
+
+func TestBatchGeoService_SearchBatch(t *testing.T) {
+	provider := &echoProvider{}
+	serv := NewBatchGeoService(NewGeoService(provider, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), config.Batch{MaxSize: 20, Concurrency: 3})
+
+	queries := []string{"Москва", "fail 1", "Тверь", "Казань", "fail 2", "Самара", "Омск", "Томск", "Пермь", "Уфа"}
+	results, err := serv.SearchBatch(context.Background(), queries)
+	assert.NoError(t, err)
+	assert.Len(t, results, len(queries))
+	for i, result := range results {
+		if i == 1 || i == 4 {
+			assert.Error(t, result.Err)
+			assert.Nil(t, result.Response)
+			continue
+		}
+		assert.NoError(t, result.Err)
+		assert.Equal(t, queries[i], result.Response.Addresses[0].Address)
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&provider.peak), int32(3))
+	assert.Greater(t, atomic.LoadInt32(&provider.peak), int32(1))
+
+	_, err = serv.SearchBatch(context.Background(), make([]string, 21))
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+}
+
+func TestBatchGeoService_GeocodeBatch(t *testing.T) {
+	serv := NewBatchGeoService(NewGeoService(&echoProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), config.Batch{MaxSize: 2, Concurrency: 0})
+
+	results, err := serv.GeocodeBatch(context.Background(), []GeocodePoint{{Lat: "55.878", Lon: "37.653"}, {Lat: "59.936", Lon: "30.315"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "55.878,37.653", results[0].Response.Addresses[0].Address)
+	assert.Equal(t, "59.936,30.315", results[1].Response.Addresses[0].Address)
+
+	_, err = serv.GeocodeBatch(context.Background(), make([]GeocodePoint, 3))
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+}
+
+func TestBatchGeoService_InvalidCoordinates(t *testing.T) {
+	provider := &countingProvider{}
+	serv := NewBatchGeoService(NewGeoService(provider, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), config.Batch{MaxSize: 3})
+
+	_, err := serv.GetGeoResp(context.Background(), `55.878"`, "37.653")
+	assert.ErrorIs(t, err, ErrInvalidCoordinates)
+
+	results, err := serv.GeocodeBatch(context.Background(), []GeocodePoint{{Lat: "55.878", Lon: "37.653"}, {Lat: "NaN", Lon: "37.653"}, {Lat: "91", Lon: "37.653"}})
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrInvalidCoordinates)
+	assert.ErrorIs(t, results[2].Err, ErrInvalidCoordinates)
+	// only the valid point reached the provider
+	assert.Equal(t, 1, provider.geocodes)
+}
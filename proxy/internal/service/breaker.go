@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"test/proxy/internal/breaker"
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+)
+
+// ProviderBreakerStats is the breaker state of one geocoding provider.
+type ProviderBreakerStats struct {
+	Provider string
+	breaker.Stats
+}
+
+// CircuitBreakerProvider guards a geocoding provider with a circuit breaker.
+// While the breaker is open lookups fail fast with an error wrapping
+// breaker.ErrOpen instead of waiting for the provider, so a failover chain
+// moves on to the next provider at once.
+type CircuitBreakerProvider struct {
+	GeocodingProvider
+	breaker *breaker.Breaker
+}
+
+func NewCircuitBreakerProvider(next GeocodingProvider, conf config.Breaker) *CircuitBreakerProvider {
+	return &CircuitBreakerProvider{
+		GeocodingProvider: next,
+		breaker:           breaker.New(conf.FailureThreshold, conf.SuccessThreshold, conf.CoolDown),
+	}
+}
+
+func (c *CircuitBreakerProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("geocoding provider unavailable: %w", err)
+	}
+	addresses, err := c.GeocodingProvider.Search(ctx, query)
+	c.done(ctx, err)
+	return addresses, err
+}
+
+func (c *CircuitBreakerProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("geocoding provider unavailable: %w", err)
+	}
+	addresses, err := c.GeocodingProvider.Geocode(ctx, lat, lon)
+	c.done(ctx, err)
+	return addresses, err
+}
+
+func (c *CircuitBreakerProvider) BreakerStats() breaker.Stats {
+	return c.breaker.Stats()
+}
+
+// done records the outcome of a call. A call failed because its caller went
+// away is inconclusive, it must not close a half-open breaker.
+func (c *CircuitBreakerProvider) done(ctx context.Context, err error) {
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		c.breaker.Release()
+		return
+	}
+	c.breaker.Done(!isProviderFailure(ctx, err))
+}
+
+// isProviderFailure reports whether err means the provider is unhealthy.
+// Invalid input, client errors and callers going away do not count.
+func isProviderFailure(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(ctx.Err(), context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return IsTimeout(err) || isNetworkError(err)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"test/proxy/internal/breaker"
+	"test/proxy/internal/config"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerProvider(t *testing.T) {
+	provider := &countingProvider{err: &StatusError{Provider: "test", StatusCode: 502}}
+	guarded := NewCircuitBreakerProvider(provider, config.Breaker{FailureThreshold: 2, CoolDown: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		_, err := guarded.Search(context.Background(), "Сухонская 11")
+		var statusErr *StatusError
+		assert.ErrorAs(t, err, &statusErr)
+	}
+	assert.Equal(t, breaker.Open, guarded.BreakerStats().State)
+
+	// the provider is not called while the breaker is open
+	_, err := guarded.Search(context.Background(), "Сухонская 11")
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+	_, err = guarded.Geocode(context.Background(), "55.878", "37.653")
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+	assert.Equal(t, 2, provider.searches)
+	assert.Equal(t, 0, provider.geocodes)
+	assert.Equal(t, uint64(2), guarded.BreakerStats().Rejected)
+}
+
+func TestCircuitBreakerProvider_ClientErrors(t *testing.T) {
+	provider := &countingProvider{err: &StatusError{Provider: "test", StatusCode: 400}}
+	guarded := NewCircuitBreakerProvider(provider, config.Breaker{FailureThreshold: 1, CoolDown: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		_, err := guarded.Geocode(context.Background(), "north", "37.653")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, breaker.Closed, guarded.BreakerStats().State)
+	assert.Equal(t, 3, provider.geocodes)
+}
+
+func TestCircuitBreakerProvider_CanceledProbe(t *testing.T) {
+	provider := &countingProvider{err: &StatusError{Provider: "test", StatusCode: 502}}
+	guarded := NewCircuitBreakerProvider(provider, config.Breaker{FailureThreshold: 1, CoolDown: time.Millisecond})
+	_, err := guarded.Search(context.Background(), "Сухонская 11")
+	assert.Error(t, err)
+	time.Sleep(2 * time.Millisecond)
+
+	// the caller of the probe goes away, the breaker waits for the next one
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	provider.err = context.Canceled
+	_, err = guarded.Search(canceled, "Сухонская 11")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, breaker.HalfOpen, guarded.BreakerStats().State)
+
+	provider.err = nil
+	_, err = guarded.Search(context.Background(), "Сухонская 11")
+	assert.NoError(t, err)
+	assert.Equal(t, breaker.Closed, guarded.BreakerStats().State)
+}
+
+func TestCircuitBreakerProvider_Failover(t *testing.T) {
+	failing := &countingProvider{err: &StatusError{Provider: "dadata", StatusCode: 503}}
+	healthy := &countingProvider{}
+	conf := config.Breaker{FailureThreshold: 1, CoolDown: time.Minute}
+	provider := NewFailoverProvider(
+		NamedProvider{Name: "dadata", GeocodingProvider: NewCircuitBreakerProvider(failing, conf)},
+		NamedProvider{Name: "gazetteer", GeocodingProvider: NewCircuitBreakerProvider(healthy, conf)},
+	)
+	serv := NewGeoService(provider, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+
+	for i := 0; i < 3; i++ {
+		resp, err := serv.GetSearchResp(context.Background(), "Сухонская 11")
+		assert.NoError(t, err)
+		assert.Equal(t, "gazetteer", resp.Provider)
+	}
+	// the open breaker of the first provider does not stop the second one
+	assert.Equal(t, 1, failing.searches)
+	assert.Equal(t, 3, healthy.searches)
+
+	stats := serv.(BreakerStater).BreakerStats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "dadata", stats[0].Provider)
+	assert.Equal(t, breaker.Open, stats[0].State)
+	assert.Equal(t, uint64(2), stats[0].Rejected)
+	assert.Equal(t, "gazetteer", stats[1].Provider)
+	assert.Equal(t, breaker.Closed, stats[1].State)
+}
+
+func TestIsProviderFailure(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"1", context.Background(), nil, false},
+		{"2", context.Background(), &StatusError{StatusCode: 500}, true},
+		{"3", context.Background(), fmt.Errorf("search: %w", &StatusError{StatusCode: 503}), true},
+		{"4", context.Background(), &StatusError{StatusCode: 429}, true},
+		{"5", context.Background(), &StatusError{StatusCode: 403}, false},
+		{"6", context.Background(), context.DeadlineExceeded, true},
+		{"7", context.Background(), &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"8", context.Background(), errors.New("invalid latitude"), false},
+		{"9", canceled, context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isProviderFailure(tt.ctx, tt.err))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"test/proxy/internal/cache"
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+)
+
+// CachedGeoService decorates a GeoServicer with an LRU cache of geocoding
+// responses. Everything else is passed through to the wrapped service.
+type CachedGeoService struct {
+	GeoServicer
+	cache     *cache.LRU
+	precision int
+}
+
+func NewCachedGeoService(next GeoServicer, conf config.Cache) GeoServicer {
+	precision := conf.Precision
+	if precision < 0 {
+		precision = 0
+	}
+	return &CachedGeoService{
+		GeoServicer: next,
+		cache:       cache.NewLRU(conf.Size, conf.TTL),
+		precision:   precision,
+	}
+}
+
+func (c *CachedGeoService) Unwrap() GeoServicer {
+	return c.GeoServicer
+}
+
+func (c *CachedGeoService) GetSearchResp(ctx context.Context, query string) (*responder.SearchResponse, error) {
+	key := searchKey(query)
+	if v, ok := c.cache.Get(key); ok {
+		return v.(*responder.SearchResponse), nil
+	}
+
+	resp, err := c.GeoServicer.GetSearchResp(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Set(key, resp)
+	return resp, nil
+}
+
+func (c *CachedGeoService) GetGeoResp(ctx context.Context, lat, lon string) (*responder.GeocodeResponse, error) {
+	pointLat, pointLon, err := parseCoordinates(lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	key := geocodeKey(pointLat, pointLon, c.precision)
+	if v, ok := c.cache.Get(key); ok {
+		return v.(*responder.GeocodeResponse), nil
+	}
+
+	resp, err := c.GeoServicer.GetGeoResp(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Set(key, resp)
+	return resp, nil
+}
+
+func (c *CachedGeoService) CacheStats() cache.Stats {
+	return c.cache.Stats()
+}
+
+func (c *CachedGeoService) FlushCache() {
+	c.cache.Flush()
+}
+
+func searchKey(query string) string {
+	return "search:" + normalizeQuery(query)
+}
+
+// geocodeKey rounds coordinates to precision decimals, so nearby points share
+// a key. A negative precision keeps them as is.
+func geocodeKey(lat, lon float64, precision int) string {
+	return "geocode:" + coordinateKey(lat, precision) + "," + coordinateKey(lon, precision)
+}
+
+func coordinateKey(deg float64, precision int) string {
+	if precision >= 0 {
+		scale := math.Pow(10, float64(precision))
+		deg = math.Round(deg*scale) / scale
+	}
+	if deg == 0 {
+		// avoid a separate key for -0
+		deg = 0
+	}
+	return strconv.FormatFloat(deg, 'f', precision, 64)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"test/proxy/internal/cache"
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingProvider counts upstream calls per method
+type countingProvider struct {
+	mu       sync.Mutex
+	searches int
+	geocodes int
+	err      error
+}
+
+func (p *countingProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	p.mu.Lock()
+	p.searches++
+	p.mu.Unlock()
+	return []*responder.Address{{Address: query}}, p.err
+}
+
+func (p *countingProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	p.mu.Lock()
+	p.geocodes++
+	p.mu.Unlock()
+	return []*responder.Address{{Address: lat + "," + lon}}, p.err
+}
+
+func newCachedTestService(provider GeocodingProvider, conf config.Cache) *CachedGeoService {
+	serv := NewGeoService(provider, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	return NewCachedGeoService(serv, conf).(*CachedGeoService)
+}
+
+func TestCachedGeoService_GetSearchResp(t *testing.T) {
+	provider := &countingProvider{}
+	serv := newCachedTestService(provider, config.Cache{Size: 10, TTL: time.Hour, Precision: 4})
+
+	for _, query := range []string{"Сухонская 11", "  сухонская   11 ", "СУХОНСКАЯ 11"} {
+		got, err := serv.GetSearchResp(context.Background(), query)
+		assert.NoError(t, err)
+		assert.Equal(t, "Сухонская 11", got.Addresses[0].Address)
+	}
+	assert.Equal(t, 1, provider.searches)
+
+	_, _ = serv.GetSearchResp(context.Background(), "Ленинский 118")
+	assert.Equal(t, 2, provider.searches)
+	assert.Equal(t, cache.Stats{Hits: 2, Misses: 2, Size: 2}, serv.CacheStats())
+
+	serv.FlushCache()
+	_, _ = serv.GetSearchResp(context.Background(), "Сухонская 11")
+	assert.Equal(t, 3, provider.searches)
+}
+
+func TestCachedGeoService_GetGeoResp(t *testing.T) {
+	provider := &countingProvider{}
+	serv := newCachedTestService(provider, config.Cache{Size: 10, TTL: time.Hour, Precision: 3})
+
+	tests := []struct {
+		name    string
+		lat     string
+		lon     string
+		want    int
+		wantErr error
+	}{
+		{"1", "55.87831", "37.65372", 1, nil},
+		{"2", "55.8783", "37.6537", 1, nil},
+		{"3", " 55.878 ", "37.654", 1, nil},
+		{"4", "55.879", "37.654", 2, nil},
+		{"5", "-0.0001", "0.0001", 3, nil},
+		{"6", "0.0001", "-0.0001", 3, nil},
+		{"7", "north", "37.65", 3, ErrInvalidCoordinates},
+		{"8", "55.878", "181", 3, ErrInvalidCoordinates},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := serv.GetGeoResp(context.Background(), tt.lat, tt.lon)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, provider.geocodes)
+		})
+	}
+}
+
+func TestCachedGeoService_Errors(t *testing.T) {
+	provider := &countingProvider{err: errors.New("provider error")}
+	serv := newCachedTestService(provider, config.Cache{Size: 10, TTL: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		_, err := serv.GetSearchResp(context.Background(), "Сухонская 11")
+		assert.Error(t, err)
+		_, err = serv.GetGeoResp(context.Background(), "55.878", "37.653")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 2, provider.searches)
+	assert.Equal(t, 2, provider.geocodes)
+	assert.Equal(t, 0, serv.CacheStats().Size)
+}
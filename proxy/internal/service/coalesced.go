@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+
+	"test/proxy/internal/coalesce"
+	"test/proxy/internal/responder"
+)
+
+// CoalescingGeoService decorates a GeoServicer so that concurrent identical
+// geocoding lookups share a single provider call. The shared call runs until
+// its last caller gives up, the provider timeout still applies to it.
+type CoalescingGeoService struct {
+	GeoServicer
+	group *coalesce.Group
+}
+
+func NewCoalescingGeoService(next GeoServicer) GeoServicer {
+	return &CoalescingGeoService{GeoServicer: next, group: coalesce.NewGroup()}
+}
+
+func (c *CoalescingGeoService) Unwrap() GeoServicer {
+	return c.GeoServicer
+}
+
+func (c *CoalescingGeoService) GetSearchResp(ctx context.Context, query string) (*responder.SearchResponse, error) {
+	v, err, _ := c.group.Do(ctx, searchKey(query), func(ctx context.Context) (interface{}, error) {
+		return c.GeoServicer.GetSearchResp(ctx, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*responder.SearchResponse), nil
+}
+
+func (c *CoalescingGeoService) GetGeoResp(ctx context.Context, lat, lon string) (*responder.GeocodeResponse, error) {
+	pointLat, pointLon, err := parseCoordinates(lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err, _ := c.group.Do(ctx, geocodeKey(pointLat, pointLon, -1), func(ctx context.Context) (interface{}, error) {
+		return c.GeoServicer.GetGeoResp(ctx, lat, lon)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*responder.GeocodeResponse), nil
+}
+
+func (c *CoalescingGeoService) CoalescingStats() coalesce.Stats {
+	return c.group.Stats()
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingProvider holds every call until release is closed
+type blockingProvider struct {
+	countingProvider
+	release chan struct{}
+}
+
+func (p *blockingProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	<-p.release
+	return p.countingProvider.Search(ctx, query)
+}
+
+func (p *blockingProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	<-p.release
+	return p.countingProvider.Geocode(ctx, lat, lon)
+}
+
+func TestCoalescingGeoService(t *testing.T) {
+	provider := &blockingProvider{release: make(chan struct{})}
+	serv := NewCoalescingGeoService(NewGeoService(provider, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)).(*CoalescingGeoService)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			got, err := serv.GetSearchResp(context.Background(), "Сухонская 11")
+			assert.NoError(t, err)
+			assert.Len(t, got.Addresses, 1)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := serv.GetSearchResp(context.Background(), " сухонская  11")
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			got, err := serv.GetGeoResp(context.Background(), "55.878", "37.653")
+			assert.NoError(t, err)
+			assert.Len(t, got.Addresses, 1)
+		}()
+	}
+
+	for serv.CoalescingStats().Coalesced < 3*callers-2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(provider.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, provider.searches)
+	assert.Equal(t, 1, provider.geocodes)
+	stats := serv.CoalescingStats()
+	assert.Equal(t, uint64(2), stats.Calls)
+	assert.Equal(t, uint64(3*callers-2), stats.Coalesced)
+
+	// sequential calls are not coalesced
+	_, _ = serv.GetGeoResp(context.Background(), "55.878", "37.653")
+	assert.Equal(t, 2, provider.geocodes)
+	_, err := serv.GetGeoResp(context.Background(), "north", "37.653")
+	assert.ErrorIs(t, err, ErrInvalidCoordinates)
+	assert.Equal(t, 2, provider.geocodes)
+	assert.Equal(t, uint64(3), serv.CoalescingStats().Calls)
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCoordinates is returned for coordinates that are not numbers or
+// out of range. BatchGeoService rejects them before any provider is asked.
+var ErrInvalidCoordinates = errors.New("invalid coordinates")
+
+func parseCoordinates(lat, lon string) (float64, float64, error) {
+	pointLat, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil || math.IsNaN(pointLat) || pointLat < -90 || pointLat > 90 {
+		return 0, 0, fmt.Errorf("%w: latitude %q", ErrInvalidCoordinates, lat)
+	}
+	pointLon, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil || math.IsNaN(pointLon) || pointLon < -180 || pointLon > 180 {
+		return 0, 0, fmt.Errorf("%w: longitude %q", ErrInvalidCoordinates, lon)
+	}
+	return pointLat, pointLon, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"test/proxy/internal/breaker"
+	"test/proxy/internal/responder"
+)
+
+// NamedProvider is a GeocodingProvider with the name reported to clients
+// when it answers a lookup.
+type NamedProvider struct {
+	Name string
+	GeocodingProvider
+}
+
+// SourceProvider is a GeocodingProvider that also reports which backend
+// answered a lookup.
+type SourceProvider interface {
+	GeocodingProvider
+	SearchSource(ctx context.Context, query string) ([]*responder.Address, string, error)
+	GeocodeSource(ctx context.Context, lat, lon string) ([]*responder.Address, string, error)
+}
+
+// FailoverProvider asks its providers in order and returns the first
+// non-empty result. A provider that fails, times out or finds nothing is
+// followed by the next one.
+type FailoverProvider struct {
+	providers []NamedProvider
+}
+
+func NewFailoverProvider(providers ...NamedProvider) *FailoverProvider {
+	return &FailoverProvider{providers: providers}
+}
+
+func (f *FailoverProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	addresses, _, err := f.SearchSource(ctx, query)
+	return addresses, err
+}
+
+func (f *FailoverProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	addresses, _, err := f.GeocodeSource(ctx, lat, lon)
+	return addresses, err
+}
+
+func (f *FailoverProvider) SearchSource(ctx context.Context, query string) ([]*responder.Address, string, error) {
+	return f.lookup(ctx, func(p GeocodingProvider) ([]*responder.Address, error) {
+		return p.Search(ctx, query)
+	})
+}
+
+func (f *FailoverProvider) GeocodeSource(ctx context.Context, lat, lon string) ([]*responder.Address, string, error) {
+	return f.lookup(ctx, func(p GeocodingProvider) ([]*responder.Address, error) {
+		return p.Geocode(ctx, lat, lon)
+	})
+}
+
+// BreakerStats reports the breakers of the providers guarded by one, in the
+// order the providers are asked.
+func (f *FailoverProvider) BreakerStats() []ProviderBreakerStats {
+	stats := make([]ProviderBreakerStats, 0, len(f.providers))
+	for _, p := range f.providers {
+		if b, ok := p.GeocodingProvider.(interface{ BreakerStats() breaker.Stats }); ok {
+			stats = append(stats, ProviderBreakerStats{Provider: p.Name, Stats: b.BreakerStats()})
+		}
+	}
+	return stats
+}
+
+// lookup returns the first non-empty result. When nobody finds anything the
+// empty result of the last provider that answered is returned, when all of
+// them fail the error of the last one.
+func (f *FailoverProvider) lookup(ctx context.Context, fn func(GeocodingProvider) ([]*responder.Address, error)) ([]*responder.Address, string, error) {
+	var (
+		empty    []*responder.Address
+		answered string
+		lastErr  error
+	)
+	for _, p := range f.providers {
+		addresses, err := fn(p.GeocodingProvider)
+		if err == nil && len(addresses) > 0 {
+			return addresses, p.Name, nil
+		}
+		if err == nil {
+			empty, answered = addresses, p.Name
+		} else {
+			lastErr = fmt.Errorf("provider %s: %w", p.Name, err)
+		}
+		// the caller is gone, nobody is waiting for the next provider
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if answered != "" {
+		return empty, answered, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no geocoding providers")
+	}
+	return nil, "", lastErr
+}
+
+// lookupSearch asks provider, reporting its source when it knows it.
+func lookupSearch(ctx context.Context, provider GeocodingProvider, query string) ([]*responder.Address, string, error) {
+	if sp, ok := provider.(SourceProvider); ok {
+		return sp.SearchSource(ctx, query)
+	}
+	addresses, err := provider.Search(ctx, query)
+	return addresses, "", err
+}
+
+// lookupGeocode asks provider, reporting its source when it knows it.
+func lookupGeocode(ctx context.Context, provider GeocodingProvider, lat, lon string) ([]*responder.Address, string, error) {
+	if sp, ok := provider.(SourceProvider); ok {
+		return sp.GeocodeSource(ctx, lat, lon)
+	}
+	addresses, err := provider.Geocode(ctx, lat, lon)
+	return addresses, "", err
+}
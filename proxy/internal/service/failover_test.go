@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoverProvider(t *testing.T) {
+	primary := []*responder.Address{{Address: "г Москва, ул Сухонская, д 11"}}
+	secondary := []*responder.Address{{Address: "Сухонская улица, 11"}}
+	failing := &stubProvider{err: errors.New("provider error")}
+	timingOut := &stubProvider{err: context.DeadlineExceeded}
+	empty := &stubProvider{}
+
+	tests := []struct {
+		name       string
+		providers  []NamedProvider
+		want       []*responder.Address
+		wantSource string
+		wantErr    bool
+	}{
+		{"1", []NamedProvider{{"dadata", &stubProvider{addresses: primary}}, {"gazetteer", &stubProvider{addresses: secondary}}}, primary, "dadata", false},
+		{"2", []NamedProvider{{"dadata", failing}, {"gazetteer", &stubProvider{addresses: secondary}}}, secondary, "gazetteer", false},
+		{"3", []NamedProvider{{"dadata", timingOut}, {"gazetteer", &stubProvider{addresses: secondary}}}, secondary, "gazetteer", false},
+		{"4", []NamedProvider{{"dadata", empty}, {"gazetteer", &stubProvider{addresses: secondary}}}, secondary, "gazetteer", false},
+		{"5", []NamedProvider{{"dadata", empty}, {"gazetteer", failing}}, nil, "dadata", false},
+		{"6", []NamedProvider{{"dadata", failing}, {"gazetteer", timingOut}}, nil, "", true},
+		{"7", nil, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFailoverProvider(tt.providers...)
+
+			got, source, err := f.SearchSource(context.Background(), "Сухонская 11")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantSource, source)
+
+			got, source, err = f.GeocodeSource(context.Background(), "55.878", "37.653")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantSource, source)
+		})
+	}
+}
+
+func TestFailoverProvider_Errors(t *testing.T) {
+	f := NewFailoverProvider(NamedProvider{"dadata", &stubProvider{err: errors.New("provider error")}}, NamedProvider{"gazetteer", &stubProvider{err: context.DeadlineExceeded}})
+	_, err := f.Search(context.Background(), "Сухонская 11")
+	assert.True(t, IsTimeout(err))
+	assert.EqualError(t, err, "provider gazetteer: context deadline exceeded")
+
+	// nobody is asked after the caller has gone away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	second := &countingProvider{}
+	f = NewFailoverProvider(NamedProvider{"dadata", &stubProvider{err: context.Canceled}}, NamedProvider{"gazetteer", second})
+	_, err = f.Geocode(ctx, "55.878", "37.653")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, second.geocodes)
+}
+
+func TestGeoService_Provider(t *testing.T) {
+	addresses := []*responder.Address{{Address: "Сухонская улица, 11"}}
+	f := NewFailoverProvider(NamedProvider{"dadata", &stubProvider{}}, NamedProvider{"gazetteer", &stubProvider{addresses: addresses}})
+	serv := NewGeoService(f, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+
+	search, err := serv.GetSearchResp(context.Background(), "Сухонская 11")
+	assert.NoError(t, err)
+	assert.Equal(t, "gazetteer", search.Provider)
+
+	geocode, err := serv.GetGeoResp(context.Background(), "55.878", "37.653")
+	assert.NoError(t, err)
+	assert.Equal(t, "gazetteer", geocode.Provider)
+
+	// a single provider does not know its name
+	serv = NewGeoService(&stubProvider{addresses: addresses}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	search, err = serv.GetSearchResp(context.Background(), "Сухонская 11")
+	assert.NoError(t, err)
+	assert.Empty(t, search.Provider)
+}
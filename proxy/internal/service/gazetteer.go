@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+	"test/proxy/internal/spatial"
+
+	"github.com/ptflp/godecoder"
+)
+
+// Gazetteer is a GeocodingProvider that answers from a local dataset of
+// addresses loaded at startup, so the service can run without network access.
+type Gazetteer struct {
+	entries  []gazetteerEntry
+	index    *spatial.Grid
+	limit    int
+	nearestK int
+	radius   float64
+}
+
+type gazetteerEntry struct {
+	address    string
+	normalized string
+	lat        float64
+	lon        float64
+}
+
+// LoadGazetteer reads a CSV (address,lat,lon) or GeoJSON FeatureCollection of
+// Point features. The format is picked by the file extension.
+func LoadGazetteer(decoder godecoder.Decoder, conf config.Gazetteer) (GeocodingProvider, error) {
+	path := conf.File
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error open gazetteer file: %v", err)
+	}
+	defer f.Close()
+
+	var entries []gazetteerEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readGazetteerCSV(f)
+	case ".geojson", ".json":
+		entries, err = readGazetteerGeoJSON(decoder, f)
+	default:
+		return nil, fmt.Errorf("unsupported gazetteer file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error read gazetteer file %s: %v", path, err)
+	}
+
+	return newGazetteer(entries, conf), nil
+}
+
+func newGazetteer(entries []gazetteerEntry, conf config.Gazetteer) *Gazetteer {
+	index := spatial.NewGrid(conf.CellSize)
+	for i := range entries {
+		entries[i].normalized = normalizeQuery(entries[i].address)
+		index.Insert(spatial.Point{Lat: entries[i].lat, Lon: entries[i].lon})
+	}
+	return &Gazetteer{
+		entries:  entries,
+		index:    index,
+		limit:    conf.Limit,
+		nearestK: conf.NearestK,
+		radius:   conf.Radius,
+	}
+}
+
+func (g *Gazetteer) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	query = normalizeQuery(query)
+	if query == "" {
+		return []*responder.Address{}, nil
+	}
+
+	var prefix, substr []*responder.Address
+	for _, e := range g.entries {
+		switch {
+		case strings.HasPrefix(e.normalized, query):
+			prefix = append(prefix, e.toAddress())
+		case strings.Contains(e.normalized, query):
+			substr = append(substr, e.toAddress())
+		}
+	}
+
+	addresses := append(prefix, substr...)
+	if addresses == nil {
+		addresses = []*responder.Address{}
+	}
+	if g.limit > 0 && len(addresses) > g.limit {
+		addresses = addresses[:g.limit]
+	}
+
+	return addresses, nil
+}
+
+// Geocode returns the nearestK addresses within radius meters of the point,
+// sorted by great-circle distance.
+func (g *Gazetteer) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pointLat, pointLon, err := parseCoordinates(lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors := g.index.Nearest(spatial.Point{Lat: pointLat, Lon: pointLon}, g.nearestK, g.radius)
+
+	addresses := make([]*responder.Address, len(neighbors))
+	for i, n := range neighbors {
+		addresses[i] = g.entries[n.ID].toAddress()
+		addresses[i].Distance = n.Distance
+	}
+
+	return addresses, nil
+}
+
+func (e gazetteerEntry) toAddress() *responder.Address {
+	return &responder.Address{Address: e.address, Lat: e.lat, Lon: e.lon}
+}
+
+func readGazetteerCSV(r io.Reader) ([]gazetteerEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	if len(records[0]) < 3 {
+		return nil, fmt.Errorf("expected address,lat,lon columns")
+	}
+
+	columns := map[string]int{"address": 0, "lat": 1, "lon": 2}
+	if _, err := strconv.ParseFloat(records[0][1], 64); err != nil {
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		records = records[1:]
+	}
+
+	entries := make([]gazetteerEntry, 0, len(records))
+	for i, rec := range records {
+		lat, lon, err := parseCoordinates(rec[columns["lat"]], rec[columns["lon"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		entries = append(entries, gazetteerEntry{address: rec[columns["address"]], lat: lat, lon: lon})
+	}
+
+	return entries, nil
+}
+
+type featureCollection struct {
+	Features []struct {
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+func readGazetteerGeoJSON(decoder godecoder.Decoder, r io.Reader) ([]gazetteerEntry, error) {
+	fc := featureCollection{}
+	if err := decoder.Decode(r, &fc); err != nil {
+		return nil, err
+	}
+
+	entries := make([]gazetteerEntry, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		if f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) < 2 {
+			return nil, fmt.Errorf("feature %d: expected Point geometry", i)
+		}
+		address, _ := f.Properties["address"].(string)
+		if address == "" {
+			address, _ = f.Properties["name"].(string)
+		}
+		entries = append(entries, gazetteerEntry{
+			address: address,
+			lat:     f.Geometry.Coordinates[1],
+			lon:     f.Geometry.Coordinates[0],
+		})
+	}
+
+	return entries, nil
+}
+
+func normalizeQuery(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
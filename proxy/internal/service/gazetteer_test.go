@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"test/proxy/internal/config"
+
+	"github.com/ptflp/godecoder"
+	"github.com/stretchr/testify/assert"
+)
+
+var mockGazetteerCSV = `address,lat,lon
+"г Москва, ул Сухонская, д 11",55.878315,37.65372
+"г Москва, ул Сухонская, д 13",55.878666,37.6524
+"г Москва, ул Сухонская, д 9",55.877167,37.652481
+"г Санкт-Петербург, Ленинский пр-кт, д 118 к 1",59.851461,30.264849
+`
+
+var mockGazetteerGeoJSON = `{"type":"FeatureCollection","features":[
+	{"type":"Feature","geometry":{"type":"Point","coordinates":[37.65372,55.878315]},"properties":{"address":"г Москва, ул Сухонская, д 11"}},
+	{"type":"Feature","geometry":{"type":"Point","coordinates":[30.264849,59.851461]},"properties":{"name":"г Санкт-Петербург, Ленинский пр-кт, д 118 к 1"}}
+]}`
+
+func writeGazetteer(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadGazetteer(t *testing.T) {
+	decoder := godecoder.NewDecoder()
+
+	tests := []struct {
+		name    string
+		path    string
+		want    int
+		wantErr bool
+	}{
+		{"1", writeGazetteer(t, "gazetteer.csv", mockGazetteerCSV), 4, false},
+		{"2", writeGazetteer(t, "gazetteer.geojson", mockGazetteerGeoJSON), 2, false},
+		{"3", writeGazetteer(t, "gazetteer.txt", mockGazetteerCSV), 0, true},
+		{"4", writeGazetteer(t, "broken.csv", "address,lat,lon\nМосква,north,east\n"), 0, true},
+		{"5", filepath.Join(t.TempDir(), "missing.csv"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadGazetteer(decoder, config.Gazetteer{File: tt.path})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadGazetteer() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Len(t, got.(*Gazetteer).entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestGazetteer_Search(t *testing.T) {
+	provider, err := LoadGazetteer(godecoder.NewDecoder(), config.Gazetteer{File: writeGazetteer(t, "gazetteer.csv", mockGazetteerCSV), Limit: 2})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"1", "г москва", []string{"г Москва, ул Сухонская, д 11", "г Москва, ул Сухонская, д 13"}},
+		{"2", "  ЛЕНИНСКИЙ  ", []string{"г Санкт-Петербург, Ленинский пр-кт, д 118 к 1"}},
+		{"3", "Сухонская, д 9", []string{"г Москва, ул Сухонская, д 9"}},
+		{"4", "Казань", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.Search(context.Background(), tt.query)
+			assert.NoError(t, err)
+			addresses := make([]string, len(got))
+			for i, a := range got {
+				addresses[i] = a.Address
+			}
+			assert.Equal(t, tt.want, addresses)
+		})
+	}
+}
+
+func TestGazetteer_Geocode(t *testing.T) {
+	provider, err := LoadGazetteer(godecoder.NewDecoder(), config.Gazetteer{
+		File:     writeGazetteer(t, "gazetteer.csv", mockGazetteerCSV),
+		NearestK: 2,
+		Radius:   1000,
+		CellSize: 0.01,
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		lat     string
+		lon     string
+		want    []string
+		wantErr bool
+	}{
+		{"1", "55.8786", "37.6525", []string{"г Москва, ул Сухонская, д 13", "г Москва, ул Сухонская, д 11"}, false},
+		{"2", "59.851", "30.265", []string{"г Санкт-Петербург, Ленинский пр-кт, д 118 к 1"}, false},
+		{"3", "59.93986890851519", "30.26046752929688", []string{}, false},
+		{"4", "north", "30.26", nil, true},
+		{"5", "95", "30.26", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.Geocode(context.Background(), tt.lat, tt.lon)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Gazetteer.Geocode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				assert.ErrorIs(t, err, ErrInvalidCoordinates)
+				return
+			}
+			addresses := make([]string, len(got))
+			for i, a := range got {
+				addresses[i] = a.Address
+				assert.Greater(t, a.Distance, 0.0)
+				if i > 0 {
+					assert.GreaterOrEqual(t, a.Distance, got[i-1].Distance)
+				}
+			}
+			assert.Equal(t, tt.want, addresses)
+		})
+	}
+}
+
+func TestGazetteer_Canceled(t *testing.T) {
+	provider, err := LoadGazetteer(godecoder.NewDecoder(), config.Gazetteer{File: writeGazetteer(t, "gazetteer.csv", mockGazetteerCSV)})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = provider.Search(ctx, "москва")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = provider.Geocode(ctx, "55.8786", "37.6525")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"test/proxy/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// HistoryPage is a page of the search history, Total counts every entry.
+type HistoryPage struct {
+	Entries []storage.HistoryEntry
+	Total   int
+}
+
+// HistoryGeoService decorates a GeoServicer with a search history of each
+// user, kept in a store of its own. A failure to record a lookup is logged,
+// it does not fail the lookup.
+type HistoryGeoService struct {
+	GeoServicer
+	history storage.HistoryRepository
+	logger  *zap.Logger
+}
+
+func NewHistoryGeoService(next GeoServicer, history storage.HistoryRepository, logger *zap.Logger) GeoServicer {
+	return &HistoryGeoService{GeoServicer: next, history: history, logger: logger}
+}
+
+func (h *HistoryGeoService) Unwrap() GeoServicer {
+	return h.GeoServicer
+}
+
+func (h *HistoryGeoService) RecordHistory(login string, entry storage.HistoryEntry) {
+	// tokens of unregistered callers have no user to keep a history for
+	if !h.IsUserExist(login) {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if err := h.history.Add(login, entry); err != nil {
+		h.logger.Error("history entry lost",
+			zap.Error(err),
+			zap.String("login", login),
+			zap.String("kind", entry.Kind),
+		)
+	}
+}
+
+// History returns up to limit lookups of login, most recent first, skipping
+// the first offset.
+func (h *HistoryGeoService) History(login string, offset, limit int) (*HistoryPage, error) {
+	if _, err := h.GetUser(login); err != nil {
+		return nil, err
+	}
+	entries, total, err := h.history.List(login, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error get history of %s: %w", login, err)
+	}
+	return &HistoryPage{Entries: entries, Total: total}, nil
+}
+
+func (h *HistoryGeoService) ClearHistory(login string) error {
+	if _, err := h.GetUser(login); err != nil {
+		return err
+	}
+	if err := h.history.Clear(login); err != nil {
+		return fmt.Errorf("error clear history of %s: %w", login, err)
+	}
+	return nil
+}
+
+func (h *HistoryGeoService) DeleteAccount(login, passw, ip string) error {
+	if err := h.GeoServicer.DeleteAccount(login, passw, ip); err != nil {
+		return err
+	}
+	h.clearDeleted(login)
+	return nil
+}
+
+func (h *HistoryGeoService) DeleteUser(admin, login string) error {
+	if err := h.GeoServicer.DeleteUser(admin, login); err != nil {
+		return err
+	}
+	h.clearDeleted(login)
+	return nil
+}
+
+// clearDeleted drops the history of a deleted user, so it is not handed to
+// the next user registering the login.
+func (h *HistoryGeoService) clearDeleted(login string) {
+	if err := h.history.Clear(login); err != nil {
+		h.logger.Error("history of deleted user not cleared", zap.Error(err), zap.String("login", login))
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type failingHistoryRepository struct {
+	*storage.MemoryHistoryRepository
+}
+
+func (failingHistoryRepository) Add(login string, entry storage.HistoryEntry) error {
+	return errors.New("disk full")
+}
+
+func TestHistoryGeoService(t *testing.T) {
+	history := storage.NewMemoryHistoryRepository(3)
+	serv := NewHistoryGeoService(NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), history, zap.NewNop()).(*HistoryGeoService)
+	_, err := serv.Register("User1", "qwerty")
+	assert.NoError(t, err)
+
+	for _, query := range []string{"Москва", "Тверь", "Казань", "Самара"} {
+		serv.RecordHistory("User1", storage.HistoryEntry{Kind: storage.HistorySearch, Query: query, Addresses: []storage.Address{{Address: "г " + query}}})
+	}
+	// callers without an account have no history
+	serv.RecordHistory("Unknown", storage.HistoryEntry{Kind: storage.HistorySearch, Query: "Москва"})
+	_, total, _ := history.List("Unknown", 0, 0)
+	assert.Equal(t, 0, total)
+
+	tests := []struct {
+		name   string
+		offset int
+		limit  int
+		want   []string
+	}{
+		{"1", 0, 10, []string{"Самара", "Казань", "Тверь"}},
+		{"2", 1, 1, []string{"Казань"}},
+		{"3", 3, 10, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := serv.History("User1", tt.offset, tt.limit)
+			assert.NoError(t, err)
+			queries := []string{}
+			for _, entry := range page.Entries {
+				assert.False(t, entry.Time.IsZero())
+				queries = append(queries, entry.Query)
+			}
+			assert.Equal(t, tt.want, queries)
+			assert.Equal(t, 3, page.Total)
+		})
+	}
+
+	_, err = serv.History("Unknown", 0, 10)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	assert.NoError(t, serv.ClearHistory("User1"))
+	page, err := serv.History("User1", 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, page.Entries)
+	assert.ErrorIs(t, serv.ClearHistory("Unknown"), storage.ErrUserNotFound)
+
+	// a deleted user takes the history along
+	serv.RecordHistory("User1", storage.HistoryEntry{Kind: storage.HistorySearch, Query: "Москва"})
+	assert.NoError(t, serv.DeleteAccount("User1", "qwerty", ""))
+	_, total, _ = history.List("User1", 0, 0)
+	assert.Equal(t, 0, total)
+
+	// so does a user deleted by an admin
+	_, err = serv.Register("User2", "qwerty")
+	assert.NoError(t, err)
+	serv.RecordHistory("User2", storage.HistoryEntry{Kind: storage.HistorySearch, Query: "Москва"})
+	assert.NoError(t, serv.DeleteUser("Admin", "User2"))
+	_, total, _ = history.List("User2", 0, 0)
+	assert.Equal(t, 0, total)
+}
+
+func TestHistoryGeoService_RecordFailure(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	history := failingHistoryRepository{storage.NewMemoryHistoryRepository(3)}
+	serv := NewHistoryGeoService(NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords), history, zap.New(core)).(*HistoryGeoService)
+	_, err := serv.Register("User1", "qwerty")
+	assert.NoError(t, err)
+
+	serv.RecordHistory("User1", storage.HistoryEntry{Kind: storage.HistoryGeocode, Query: "55.878,37.653"})
+
+	lost := logs.FilterMessage("history entry lost").All()
+	assert.Len(t, lost, 1)
+	assert.Equal(t, "User1", lost[0].ContextMap()["login"])
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/ratelimit"
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitError is returned when a call is rejected to stay within the
+// budget of a provider. It wraps ErrRateLimited or storage.ErrQuotaExceeded.
+type RateLimitError struct {
+	Provider string
+	// RetryAfter is when the budget allows the next call
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// LimitedProvider decorates a paid GeocodingProvider with a token bucket on
+// outbound calls and a daily quota. Calls over the rate queue for up to
+// MaxWait, calls over the quota are rejected until the next UTC day.
+type LimitedProvider struct {
+	next    GeocodingProvider
+	name    string
+	bucket  *ratelimit.Bucket
+	maxWait time.Duration
+	quota   int
+	usage   storage.UsageRepository
+	now     func() time.Time
+}
+
+func NewLimitedProvider(next GeocodingProvider, name string, conf config.Limit, usage storage.UsageRepository) GeocodingProvider {
+	return &LimitedProvider{
+		next:    next,
+		name:    name,
+		bucket:  ratelimit.NewBucket(conf.Rate, conf.Burst),
+		maxWait: conf.MaxWait,
+		quota:   conf.DailyQuota,
+		usage:   usage,
+		now:     time.Now,
+	}
+}
+
+func (p *LimitedProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return p.next.Search(ctx, query)
+}
+
+func (p *LimitedProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return p.next.Geocode(ctx, lat, lon)
+}
+
+// acquire waits for the rate limit and counts the call against the quota.
+func (p *LimitedProvider) acquire(ctx context.Context) error {
+	maxWait := p.maxWait
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < maxWait {
+		maxWait = time.Until(deadline)
+	}
+	wait, ok := p.bucket.Reserve(maxWait)
+	if !ok {
+		return &RateLimitError{Provider: p.name, RetryAfter: wait, Err: ErrRateLimited}
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	now := p.now()
+	if _, err := p.usage.Increment(p.name, now, p.quota); err != nil {
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			y, m, d := now.UTC().Date()
+			tomorrow := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+			return &RateLimitError{Provider: p.name, RetryAfter: tomorrow.Sub(now), Err: err}
+		}
+		return fmt.Errorf("count %s usage: %w", p.name, err)
+	}
+	return nil
+}
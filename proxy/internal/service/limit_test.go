@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitedProvider_Quota(t *testing.T) {
+	usage := storage.NewMemoryUsageRepository()
+	next := &countingProvider{}
+	p := NewLimitedProvider(next, "dadata", config.Limit{DailyQuota: 2}, usage).(*LimitedProvider)
+	p.now = func() time.Time { return time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC) }
+
+	_, err := p.Search(context.Background(), "Сухонская 11")
+	assert.NoError(t, err)
+	_, err = p.Geocode(context.Background(), "55.878", "37.653")
+	assert.NoError(t, err)
+
+	_, err = p.Search(context.Background(), "Сухонская 11")
+	var limitErr *RateLimitError
+	assert.ErrorAs(t, err, &limitErr)
+	assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	assert.Equal(t, time.Hour, limitErr.RetryAfter)
+	assert.Equal(t, 1, next.searches)
+	assert.Equal(t, 1, next.geocodes)
+
+	calls, _ := usage.Usage("dadata", p.now())
+	assert.Equal(t, 2, calls)
+
+	// the quota is daily
+	p.now = func() time.Time { return time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC) }
+	_, err = p.Search(context.Background(), "Сухонская 11")
+	assert.NoError(t, err)
+}
+
+func TestLimitedProvider_Rate(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      config.Limit
+		timeout   time.Duration
+		wantErr   error
+		wantCalls int
+	}{
+		{"1", config.Limit{}, time.Second, nil, 2},
+		{"2", config.Limit{Rate: 1, Burst: 1}, time.Second, ErrRateLimited, 1},
+		{"3", config.Limit{Rate: 20, Burst: 1, MaxWait: time.Second}, time.Second, nil, 2},
+		{"4", config.Limit{Rate: 1, Burst: 1, MaxWait: time.Minute}, 100 * time.Millisecond, ErrRateLimited, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &countingProvider{}
+			p := NewLimitedProvider(next, "dadata", tt.conf, storage.NewMemoryUsageRepository())
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			_, err := p.Search(ctx, "Сухонская 11")
+			assert.NoError(t, err)
+			_, err = p.Search(ctx, "Сухонская 11")
+			if tt.wantErr != nil {
+				var limitErr *RateLimitError
+				assert.ErrorAs(t, err, &limitErr)
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Greater(t, limitErr.RetryAfter, time.Duration(0))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, next.searches)
+		})
+	}
+}
+
+func TestLimitedProvider_Failover(t *testing.T) {
+	addresses := []*responder.Address{{Address: "Сухонская улица, 11"}}
+	limited := NewLimitedProvider(&countingProvider{}, "dadata", config.Limit{DailyQuota: 1}, storage.NewMemoryUsageRepository())
+	f := NewFailoverProvider(NamedProvider{"dadata", limited}, NamedProvider{"gazetteer", &stubProvider{addresses: addresses}})
+
+	_, source, err := f.SearchSource(context.Background(), "Сухонская 11")
+	assert.NoError(t, err)
+	assert.Equal(t, "dadata", source)
+
+	got, source, err := f.SearchSource(context.Background(), "Сухонская 11")
+	assert.NoError(t, err)
+	assert.Equal(t, "gazetteer", source)
+	assert.Equal(t, addresses, got)
+}
+
+type failingUsage struct{}
+
+func (failingUsage) Increment(provider string, day time.Time, limit int) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func (failingUsage) Usage(provider string, day time.Time) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestLimitedProvider_UsageError(t *testing.T) {
+	next := &countingProvider{}
+	p := NewLimitedProvider(next, "dadata", config.Limit{DailyQuota: 10}, failingUsage{})
+
+	_, err := p.Search(context.Background(), "Сухонская 11")
+	assert.EqualError(t, err, "count dadata usage: disk full")
+	assert.Equal(t, 0, next.searches)
+}
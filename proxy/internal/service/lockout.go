@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/lockout"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrAccountLocked   = errors.New("account is temporarily locked")
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+)
+
+// LoginThrottledError is returned instead of checking the password while a
+// login or client IP is throttled. It wraps ErrAccountLocked or
+// ErrTooManyAttempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%v, retry in %v", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// LockoutGeoService decorates a GeoServicer with brute-force protection of
+// Login and the other password checks. Failed attempts are counted per login
+// and per client IP, each one delays the next attempt longer and too many of
+// them lock the login or IP for a while.
+type LockoutGeoService struct {
+	GeoServicer
+	logins *lockout.Tracker
+	ips    *lockout.Tracker
+	log    *zap.Logger
+}
+
+func NewLockoutGeoService(next GeoServicer, conf config.Lockout, logger *zap.Logger) GeoServicer {
+	return &LockoutGeoService{
+		GeoServicer: next,
+		logins: lockout.NewTracker(lockout.Policy{
+			MaxFailures: conf.MaxFailures,
+			Window:      conf.Window,
+			Lockout:     conf.Duration,
+			BaseDelay:   conf.BaseDelay,
+			MaxDelay:    conf.MaxDelay,
+		}),
+		// a shared IP must not slow down every user behind it, so IPs are
+		// only locked out
+		ips: lockout.NewTracker(lockout.Policy{
+			MaxFailures: conf.MaxIPFailures,
+			Window:      conf.Window,
+			Lockout:     conf.Duration,
+		}),
+		log: logger,
+	}
+}
+
+func (l *LockoutGeoService) Unwrap() GeoServicer {
+	return l.GeoServicer
+}
+
+func (l *LockoutGeoService) Login(login, passw, ip string) (*Tokens, error) {
+	var tokens *Tokens
+	err := l.attempt(login, ip, func() (err error) {
+		tokens, err = l.GeoServicer.Login(login, passw, ip)
+		return err
+	})
+	return tokens, err
+}
+
+// ChangePassword is throttled like Login, the current password must not be
+// guessable by whoever got hold of a token.
+func (l *LockoutGeoService) ChangePassword(login, current, passw, ip string) (*Tokens, error) {
+	var tokens *Tokens
+	err := l.attempt(login, ip, func() (err error) {
+		tokens, err = l.GeoServicer.ChangePassword(login, current, passw, ip)
+		return err
+	})
+	return tokens, err
+}
+
+func (l *LockoutGeoService) DeleteAccount(login, passw, ip string) error {
+	return l.attempt(login, ip, func() error {
+		return l.GeoServicer.DeleteAccount(login, passw, ip)
+	})
+}
+
+// attempt runs check, a password check of login, unless login or ip are
+// throttled, and counts it as failed when it returns ErrInvalidCredentials.
+// The attempt is reserved before check runs, so concurrent guesses count
+// against the limits while they are in flight.
+func (l *LockoutGeoService) attempt(login, ip string, check func() error) error {
+	if status, ok := l.logins.Reserve(login); status.Locked {
+		return &LoginThrottledError{RetryAfter: status.RetryAfter, Err: ErrAccountLocked}
+	} else if !ok {
+		return &LoginThrottledError{RetryAfter: status.RetryAfter, Err: ErrTooManyAttempts}
+	}
+	if status, ok := l.ips.Reserve(ip); !ok {
+		l.logins.Release(login)
+		return &LoginThrottledError{RetryAfter: status.RetryAfter, Err: ErrTooManyAttempts}
+	}
+
+	err := check()
+	if errors.Is(err, ErrInvalidCredentials) {
+		if status, locked := l.logins.Fail(login); locked {
+			l.log.Warn("login locked out",
+				zap.String("login", login),
+				zap.String("ip", ip),
+				zap.Int("failures", status.Failures),
+				zap.Duration("duration", status.RetryAfter),
+			)
+		}
+		if status, locked := l.ips.Fail(ip); locked {
+			l.log.Warn("client ip locked out",
+				zap.String("ip", ip),
+				zap.String("login", login),
+				zap.Int("failures", status.Failures),
+				zap.Duration("duration", status.RetryAfter),
+			)
+		}
+		return err
+	}
+	l.logins.Release(login)
+	l.ips.Release(ip)
+	if err == nil {
+		l.logins.Reset(login)
+	}
+	return err
+}
+
+// UnlockLogin lifts the lockout and forgets the failed attempts of login.
+func (l *LockoutGeoService) UnlockLogin(login string) bool {
+	unlocked := l.logins.Unlock(login)
+	if unlocked {
+		l.log.Info("login unlocked", zap.String("login", login))
+	}
+	return unlocked
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newLockoutTestService(t *testing.T, conf config.Lockout) (*LockoutGeoService, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	_, err := serv.Register("User1", "qwerty")
+	assert.NoError(t, err)
+	_, err = serv.Register("User2", "qwerty")
+	assert.NoError(t, err)
+	return NewLockoutGeoService(serv, conf, zap.New(core)).(*LockoutGeoService), logs
+}
+
+func TestLockoutGeoService_Login(t *testing.T) {
+	serv, logs := newLockoutTestService(t, config.Lockout{MaxFailures: 3, MaxIPFailures: 10, Window: time.Hour, Duration: time.Hour})
+
+	tests := []struct {
+		name    string
+		login   string
+		passw   string
+		wantErr error
+	}{
+		{"1", "User1", "wrong", ErrInvalidCredentials},
+		{"2", "User1", "wrong", ErrInvalidCredentials},
+		{"3", "User1", "qwerty", nil},
+		{"4", "User1", "wrong", ErrInvalidCredentials},
+		{"5", "User1", "wrong", ErrInvalidCredentials},
+		{"6", "User1", "wrong", ErrInvalidCredentials},
+		{"7", "User1", "qwerty", ErrAccountLocked},
+		{"8", "User2", "qwerty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := serv.Login(tt.login, tt.passw, "192.0.2.1")
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	locked := logs.FilterMessage("login locked out").All()
+	assert.Len(t, locked, 1)
+	assert.Equal(t, "User1", locked[0].ContextMap()["login"])
+
+	var throttled *LoginThrottledError
+	_, err := serv.Login("User1", "qwerty", "192.0.2.1")
+	assert.ErrorAs(t, err, &throttled)
+	assert.InDelta(t, time.Hour, throttled.RetryAfter, float64(time.Second))
+
+	assert.True(t, serv.UnlockLogin("User1"))
+	assert.False(t, serv.UnlockLogin("User1"))
+	assert.Len(t, logs.FilterMessage("login unlocked").All(), 1)
+	_, err = serv.Login("User1", "qwerty", "192.0.2.1")
+	assert.NoError(t, err)
+}
+
+// slowLoginService fails every login after a while, so concurrent logins
+// overlap, and counts the password checks reaching it.
+type slowLoginService struct {
+	GeoServicer
+	checks int32
+}
+
+func (s *slowLoginService) Login(login, passw, ip string) (*Tokens, error) {
+	atomic.AddInt32(&s.checks, 1)
+	time.Sleep(20 * time.Millisecond)
+	return nil, ErrInvalidCredentials
+}
+
+func TestLockoutGeoService_Concurrent(t *testing.T) {
+	next := &slowLoginService{}
+	serv := NewLockoutGeoService(next, config.Lockout{MaxFailures: 3, MaxIPFailures: 100, Window: time.Hour, Duration: time.Hour}, zap.NewNop())
+
+	const guesses = 20
+	var wg sync.WaitGroup
+	var throttled int32
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := serv.Login("User1", "wrong", "192.0.2.1")
+			var throttledErr *LoginThrottledError
+			if errors.As(err, &throttledErr) {
+				atomic.AddInt32(&throttled, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&next.checks), int32(3))
+	assert.Equal(t, int32(guesses), atomic.LoadInt32(&next.checks)+atomic.LoadInt32(&throttled))
+	_, err := serv.Login("User1", "wrong", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
+
+func TestLockoutGeoService_Delay(t *testing.T) {
+	serv, _ := newLockoutTestService(t, config.Lockout{MaxFailures: 10, MaxIPFailures: 10, Window: time.Hour, Duration: time.Hour, BaseDelay: time.Minute})
+
+	_, err := serv.Login("User1", "wrong", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// the password is not even checked until the delay is over
+	_, err = serv.Login("User1", "qwerty", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	var throttled *LoginThrottledError
+	assert.True(t, errors.As(err, &throttled))
+	assert.InDelta(t, time.Minute, throttled.RetryAfter, float64(time.Second))
+
+	// other logins are not delayed
+	_, err = serv.Login("User2", "qwerty", "192.0.2.1")
+	assert.NoError(t, err)
+}
+
+func TestLockoutGeoService_IP(t *testing.T) {
+	serv, logs := newLockoutTestService(t, config.Lockout{MaxFailures: 10, MaxIPFailures: 2, Window: time.Hour, Duration: time.Hour})
+
+	_, _ = serv.Login("User1", "wrong", "192.0.2.1")
+	_, _ = serv.Login("unknown", "wrong", "192.0.2.1")
+	assert.Len(t, logs.FilterMessage("client ip locked out").All(), 1)
+
+	_, err := serv.Login("User2", "qwerty", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	_, err = serv.Login("User2", "qwerty", "192.0.2.2")
+	assert.NoError(t, err)
+}
+
+func TestLockoutGeoService_ChangePassword(t *testing.T) {
+	serv, _ := newLockoutTestService(t, config.Lockout{MaxFailures: 2, MaxIPFailures: 10, Window: time.Hour, Duration: time.Hour})
+
+	// wrong current passwords count towards the lockout of the login
+	_, err := serv.ChangePassword("User1", "wrong", "asdfgh", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.ErrorIs(t, serv.DeleteAccount("User1", "wrong", "192.0.2.1"), ErrInvalidCredentials)
+
+	_, err = serv.ChangePassword("User1", "qwerty", "asdfgh", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	_, err = serv.Login("User1", "qwerty", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	loginMinLength = 3
+	loginMaxLength = 64
+	// bcrypt ignores everything after the first 72 bytes
+	passwordMaxBytes = 72
+)
+
+var loginPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`)
+
+// commonPasswords are rejected regardless of their complexity, they are the
+// first ones tried by password guessing. Compared case-insensitively.
+var commonPasswords = []string{
+	// digits
+	"123456", "12345678", "123456789", "1234567890",
+	"111111", "11111111", "000000", "00000000",
+	"123123", "123321", "654321", "987654321",
+	// password itself
+	"password", "password1", "password123",
+	"passw0rd", "p@ssw0rd", "p@ssword", "p@ssword1",
+	// keyboard walks
+	"qwerty", "qwerty123", "qwerty1!", "qwerty123!", "qwertyuiop",
+	"1q2w3e4r", "1q2w3e4r5t", "1qaz2wsx", "1qaz!qaz",
+	"zaq12wsx", "zaq1@wsx", "zxcvbnm", "asdfghjkl", "qazwsx",
+	"abc123", "abcd1234", "abc12345",
+	// admin and test accounts
+	"admin", "admin123", "admin@123", "administrator",
+	"test123", "test1234", "login", "guest", "default",
+	"changeme", "changeme1", "secret", "secret123",
+	// words and names
+	"iloveyou", "iloveyou1", "welcome", "welcome1", "welcome123", "welcome@123",
+	"letmein", "letmein1", "monkey", "dragon", "football", "baseball",
+	"sunshine", "princess", "master", "shadow", "superman", "trustno1",
+	"michael", "jennifer", "starwars",
+}
+
+var commonPasswordSet = func() map[string]bool {
+	set := make(map[string]bool, len(commonPasswords))
+	for _, p := range commonPasswords {
+		set[p] = true
+	}
+	return set
+}()
+
+// seasons followed by a year around the current one, as in "Summer2025", are
+// guessed as often as the common passwords.
+var seasons = []string{"spring", "summer", "autumn", "fall", "winter"}
+
+// isSeasonal reports whether password is a season followed by the year of now,
+// the year before or the year after. Compared case-insensitively.
+func isSeasonal(password string, now time.Time) bool {
+	lower := strings.ToLower(password)
+	for _, season := range seasons {
+		if !strings.HasPrefix(lower, season) {
+			continue
+		}
+		digits := lower[len(season):]
+		year, err := strconv.Atoi(digits)
+		if err == nil && len(digits) == 4 && year >= now.Year()-1 && year <= now.Year()+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidationError lists every rule the registration input failed.
+type ValidationError struct {
+	Errors []responder.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s %s", fe.Field, fe.Message))
+	}
+	return "invalid input: " + strings.Join(msgs, "; ")
+}
+
+// PasswordPolicy validates logins and passwords chosen on registration and
+// hashes accepted passwords.
+type PasswordPolicy struct {
+	conf config.Password
+	now  func() time.Time
+
+	dummyOnce sync.Once
+	dummy     []byte
+}
+
+func NewPasswordPolicy(conf config.Password) *PasswordPolicy {
+	return &PasswordPolicy{conf: conf, now: time.Now}
+}
+
+// Validate returns every rule login and password fail, nil when they are
+// acceptable.
+func (p *PasswordPolicy) Validate(login, password string) []responder.FieldError {
+	return append(validateLogin(login), p.ValidatePassword("password", login, password)...)
+}
+
+func validateLogin(login string) []responder.FieldError {
+	var errs []responder.FieldError
+	fail := func(rule, format string, args ...interface{}) {
+		errs = append(errs, responder.FieldError{Field: "login", Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch n := utf8.RuneCountInString(login); {
+	case n == 0:
+		fail("required", "must not be empty")
+	case n < loginMinLength:
+		fail("min_length", "must be at least %d characters long", loginMinLength)
+	case n > loginMaxLength:
+		fail("max_length", "must be at most %d characters long", loginMaxLength)
+	}
+	if login != "" && !loginPattern.MatchString(login) {
+		fail("format", "must start with a letter or digit and contain only letters, digits and . _ @ -")
+	}
+	return errs
+}
+
+// ValidatePassword returns every rule a password of login fails, nil when it
+// is acceptable. field names the password in the reported errors, it is
+// checked on its own when the password is changed.
+func (p *PasswordPolicy) ValidatePassword(field, login, password string) []responder.FieldError {
+	var errs []responder.FieldError
+	fail := func(rule, format string, args ...interface{}) {
+		errs = append(errs, responder.FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if password == "" {
+		fail("required", "must not be empty")
+		return errs
+	}
+	if utf8.RuneCountInString(password) < p.conf.MinLength {
+		fail("min_length", "must be at least %d characters long", p.conf.MinLength)
+	}
+	if len(password) > passwordMaxBytes {
+		fail("max_length", "must be at most %d bytes long", passwordMaxBytes)
+	}
+	if classes := characterClasses(password); classes < p.conf.MinClasses {
+		fail("complexity", "must mix at least %d of lower case letters, upper case letters, digits and symbols", p.conf.MinClasses)
+	}
+	if p.conf.CheckCommon && (commonPasswordSet[strings.ToLower(password)] || isSeasonal(password, p.now())) {
+		fail("common", "is too common")
+	}
+	if strings.EqualFold(password, login) {
+		fail("not_login", "must differ from the login")
+	}
+	return errs
+}
+
+// Hash returns the bcrypt hash of password with the configured cost.
+func (p *PasswordPolicy) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), p.conf.BcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// dummyHash is a hash of the configured cost to compare against for unknown
+// logins, so they take as long to reject as a wrong password.
+func (p *PasswordPolicy) dummyHash() []byte {
+	p.dummyOnce.Do(func() {
+		p.dummy, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), p.conf.BcryptCost)
+	})
+	return p.dummy
+}
+
+// characterClasses counts the kinds of characters password mixes.
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	return classes
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := NewPasswordPolicy(config.Password{MinLength: 8, MinClasses: 3, CheckCommon: true, BcryptCost: bcrypt.MinCost})
+
+	tests := []struct {
+		name      string
+		login     string
+		password  string
+		wantRules []string
+	}{
+		{"1", "user1", "Sukhonskaya-11", nil},
+		{"2", "", "", []string{"login.required", "password.required"}},
+		{"3", "u1", "Sukhonskaya-11", []string{"login.min_length"}},
+		{"4", strings.Repeat("u", 65), "Sukhonskaya-11", []string{"login.max_length"}},
+		{"5", "-user", "Sukhonskaya-11", []string{"login.format"}},
+		{"6", "user 1", "Sukhonskaya-11", []string{"login.format"}},
+		{"7", "user1", "Ab1-", []string{"password.min_length"}},
+		{"8", "user1", "sukhonskaya", []string{"password.complexity"}},
+		{"9", "user1", "P@ssw0rd", []string{"password.common"}},
+		{"10", "user1", "sukhonskaya11", []string{"password.complexity"}},
+		{"11", "User.One-1", "USER.one-1", []string{"password.not_login"}},
+		{"12", "user1", strings.Repeat("Ab1-", 19), []string{"password.max_length"}},
+		{"13", "user@example.com", "пароль-Сухонская-11", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, fe := range policy.Validate(tt.login, tt.password) {
+				assert.NotEmpty(t, fe.Message)
+				rules = append(rules, fe.Field+"."+fe.Rule)
+			}
+			assert.Equal(t, tt.wantRules, rules)
+		})
+	}
+
+	// season+year variants follow the clock
+	policy.now = func() time.Time { return time.Date(2031, 6, 1, 0, 0, 0, 0, time.UTC) }
+	for _, password := range []string{"Summer2031", "WINTer2030", "faLL2032"} {
+		errs := policy.Validate("user1", password)
+		if assert.Len(t, errs, 1, password) {
+			assert.Equal(t, "common", errs[0].Rule)
+		}
+	}
+	assert.Empty(t, policy.Validate("user1", "Summer2025!"))
+	assert.Empty(t, policy.Validate("user1", "Summer2029"))
+
+	// without a minimum every non-empty password goes
+	lax := NewPasswordPolicy(config.Password{})
+	assert.Empty(t, lax.Validate("user1", "1"))
+}
+
+func TestPasswordPolicy_Hash(t *testing.T) {
+	policy := NewPasswordPolicy(config.Password{BcryptCost: bcrypt.MinCost + 1})
+
+	hash, err := policy.Hash("Sukhonskaya-11")
+	assert.NoError(t, err)
+	cost, err := bcrypt.Cost([]byte(hash))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost+1, cost)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("Sukhonskaya-11")))
+
+	_, err = NewPasswordPolicy(config.Password{BcryptCost: bcrypt.MaxCost + 1}).Hash("Sukhonskaya-11")
+	assert.Error(t, err)
+}
+
+func TestGeoService_LoginUnknown(t *testing.T) {
+	policy := NewPasswordPolicy(config.Password{BcryptCost: bcrypt.MinCost + 1})
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, policy)
+
+	// an unknown login still costs a bcrypt compare of the configured cost
+	_, err := serv.Login("unknown", "qwerty", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	cost, err := bcrypt.Cost(policy.dummy)
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost+1, cost)
+}
+
+func TestGeoService_RegisterInvalid(t *testing.T) {
+	users := storage.NewMemoryUserRepository()
+	policy := NewPasswordPolicy(config.Password{MinLength: 8, MinClasses: 3, CheckCommon: true, BcryptCost: bcrypt.MinCost})
+	serv := NewGeoService(&stubProvider{}, users, storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, policy)
+
+	_, err := serv.Register("User1", "qwerty")
+	var invalid *ValidationError
+	assert.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Errors, 3)
+	assert.Contains(t, err.Error(), "password is too common")
+	assert.False(t, serv.IsUserExist("User1"))
+
+	_, err = serv.Register("User1", "Sukhonskaya-11")
+	assert.NoError(t, err)
+	user, err := users.Get("User1")
+	assert.NoError(t, err)
+	cost, _ := bcrypt.Cost([]byte(user.Password))
+	assert.Equal(t, bcrypt.MinCost, cost)
+}
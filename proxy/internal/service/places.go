@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"test/proxy/internal/responder"
+	"test/proxy/internal/spatial"
+	"test/proxy/internal/storage"
+)
+
+const (
+	placeLabelMaxLength = 100
+	placeNotesMaxLength = 1000
+	placeTagMaxLength   = 50
+	placeMaxTags        = 20
+)
+
+// PlaceFilter selects saved places. Zero fields match every place.
+type PlaceFilter struct {
+	Tag string
+	// Query is matched case-insensitively against the label, address and
+	// notes
+	Query string
+}
+
+func (f PlaceFilter) Match(place storage.Place) bool {
+	if f.Tag != "" && !place.HasTag(f.Tag) {
+		return false
+	}
+	query := strings.ToLower(f.Query)
+	return strings.Contains(strings.ToLower(place.Label), query) ||
+		strings.Contains(strings.ToLower(place.Address.Address), query) ||
+		strings.Contains(strings.ToLower(place.Notes), query)
+}
+
+// NearbyPlace is a saved place with its distance in meters from the point
+// searched around.
+type NearbyPlace struct {
+	storage.Place
+	Distance float64
+}
+
+// ListPlaces returns the places of login matching filter, oldest first.
+func (g *GeoService) ListPlaces(login string, filter PlaceFilter) ([]storage.Place, error) {
+	user, err := g.users.Get(login)
+	if err != nil {
+		return nil, fmt.Errorf("error list places of %s: %w", login, err)
+	}
+
+	places := make([]storage.Place, 0, len(user.Places))
+	for _, place := range user.Places {
+		if filter.Match(place) {
+			places = append(places, place)
+		}
+	}
+	return places, nil
+}
+
+func (g *GeoService) GetPlace(login, id string) (storage.Place, error) {
+	user, err := g.users.Get(login)
+	if err != nil {
+		return storage.Place{}, fmt.Errorf("error get place %s: %w", id, err)
+	}
+	for _, place := range user.Places {
+		if place.ID == id {
+			return place, nil
+		}
+	}
+	return storage.Place{}, fmt.Errorf("error get place %s: %w", id, storage.ErrPlaceNotFound)
+}
+
+// CreatePlace saves place for login with a new ID. A place failing
+// validation is reported as *ValidationError.
+func (g *GeoService) CreatePlace(login string, place storage.Place) (storage.Place, error) {
+	if errs := validatePlace(place); len(errs) > 0 {
+		return storage.Place{}, &ValidationError{Errors: errs}
+	}
+
+	id, err := newTokenID()
+	if err != nil {
+		return storage.Place{}, fmt.Errorf("error create place: %v", err)
+	}
+	place.ID = id[:12]
+	place.CreatedAt = time.Now().UTC()
+	place.UpdatedAt = place.CreatedAt
+	if err := g.users.CreatePlace(login, place); err != nil {
+		return storage.Place{}, fmt.Errorf("error create place: %w", err)
+	}
+	return place, nil
+}
+
+// UpdatePlace replaces the address, label, tags and notes of the place with
+// the ID of place. A place failing validation is reported as
+// *ValidationError.
+func (g *GeoService) UpdatePlace(login string, place storage.Place) (storage.Place, error) {
+	if errs := validatePlace(place); len(errs) > 0 {
+		return storage.Place{}, &ValidationError{Errors: errs}
+	}
+
+	old, err := g.GetPlace(login, place.ID)
+	if err != nil {
+		return storage.Place{}, err
+	}
+	place.CreatedAt = old.CreatedAt
+	place.UpdatedAt = time.Now().UTC()
+	if err := g.users.UpdatePlace(login, place); err != nil {
+		return storage.Place{}, fmt.Errorf("error update place %s: %w", place.ID, err)
+	}
+	return place, nil
+}
+
+func (g *GeoService) DeletePlace(login, id string) error {
+	if err := g.users.DeletePlace(login, id); err != nil {
+		return fmt.Errorf("error delete place %s: %w", id, err)
+	}
+	return nil
+}
+
+// NearbyPlaces returns up to limit places of login within radius meters of
+// the point, nearest first. A zero limit returns every place in range.
+func (g *GeoService) NearbyPlaces(login string, lat, lon, radius float64, limit int) ([]NearbyPlace, error) {
+	user, err := g.users.Get(login)
+	if err != nil {
+		return nil, fmt.Errorf("error find places of %s: %w", login, err)
+	}
+
+	point := spatial.Point{Lat: lat, Lon: lon}
+	nearby := make([]NearbyPlace, 0)
+	for _, place := range user.Places {
+		if d := spatial.Distance(point, spatial.Point{Lat: place.Lat, Lon: place.Lon}); d <= radius {
+			nearby = append(nearby, NearbyPlace{Place: place, Distance: d})
+		}
+	}
+	sort.SliceStable(nearby, func(i, j int) bool { return nearby[i].Distance < nearby[j].Distance })
+	if limit > 0 && len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+	return nearby, nil
+}
+
+// validatePlace returns every rule place fails, nil when it is acceptable.
+func validatePlace(place storage.Place) []responder.FieldError {
+	var errs []responder.FieldError
+	fail := func(field, rule, format string, args ...interface{}) {
+		errs = append(errs, responder.FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch n := utf8.RuneCountInString(strings.TrimSpace(place.Label)); {
+	case n == 0:
+		fail("label", "required", "must not be empty")
+	case n > placeLabelMaxLength:
+		fail("label", "max_length", "must be at most %d characters long", placeLabelMaxLength)
+	}
+	if strings.TrimSpace(place.Address.Address) == "" {
+		fail("address.address", "required", "must not be empty")
+	}
+	if place.Lat < -90 || place.Lat > 90 {
+		fail("address.lat", "range", "must be between -90 and 90")
+	}
+	if place.Lon < -180 || place.Lon > 180 {
+		fail("address.lon", "range", "must be between -180 and 180")
+	}
+	if len(place.Tags) > placeMaxTags {
+		fail("tags", "max_items", "must have at most %d tags", placeMaxTags)
+	}
+	for _, tag := range place.Tags {
+		if n := utf8.RuneCountInString(tag); n == 0 || n > placeTagMaxLength || strings.TrimSpace(tag) != tag {
+			fail("tags", "format", "must be 1 to %d characters long without surrounding spaces", placeTagMaxLength)
+			break
+		}
+	}
+	if utf8.RuneCountInString(place.Notes) > placeNotesMaxLength {
+		fail("notes", "max_length", "must be at most %d characters long", placeNotesMaxLength)
+	}
+	return errs
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoService_Places(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	_, err := serv.Register("User1", "qwerty")
+	assert.NoError(t, err)
+
+	home, err := serv.CreatePlace("User1", storage.Place{Address: storage.Address{Address: "г Москва, ул Сухонская, д 11", Lat: 55.8782557, Lon: 37.65372}, Label: "Home", Tags: []string{"family"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, home.ID)
+	assert.False(t, home.CreatedAt.IsZero())
+	_, err = serv.CreatePlace("User1", storage.Place{Address: storage.Address{Address: "г Москва, Ленинский пр-кт, д 1", Lat: 55.725, Lon: 37.607}, Label: "Work", Notes: "3rd floor"})
+	assert.NoError(t, err)
+	_, err = serv.CreatePlace("User1", storage.Place{Address: storage.Address{Address: "г Санкт-Петербург, Невский пр-кт, д 1", Lat: 59.936, Lon: 30.315}, Label: "Parents", Tags: []string{"family"}})
+	assert.NoError(t, err)
+	_, err = serv.CreatePlace("Unknown", storage.Place{Address: storage.Address{Address: "г Москва"}, Label: "Home"})
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	tests := []struct {
+		name   string
+		filter PlaceFilter
+		want   []string
+	}{
+		{"1", PlaceFilter{}, []string{"Home", "Work", "Parents"}},
+		{"2", PlaceFilter{Tag: "family"}, []string{"Home", "Parents"}},
+		{"3", PlaceFilter{Query: "москва"}, []string{"Home", "Work"}},
+		{"4", PlaceFilter{Query: "FLOOR"}, []string{"Work"}},
+		{"5", PlaceFilter{Tag: "family", Query: "петербург"}, []string{"Parents"}},
+		{"6", PlaceFilter{Tag: "work"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			places, err := serv.ListPlaces("User1", tt.filter)
+			assert.NoError(t, err)
+			labels := []string{}
+			for _, place := range places {
+				labels = append(labels, place.Label)
+			}
+			assert.Equal(t, tt.want, labels)
+		})
+	}
+
+	// from the center of Moscow Work is about 3 km away, Home 14 km, Parents
+	// are in another city
+	nearby, err := serv.NearbyPlaces("User1", 55.75396, 37.620393, 20000, 0)
+	assert.NoError(t, err)
+	assert.Len(t, nearby, 2)
+	assert.Equal(t, "Work", nearby[0].Label)
+	assert.Equal(t, "Home", nearby[1].Label)
+	assert.Less(t, nearby[0].Distance, nearby[1].Distance)
+	nearby, err = serv.NearbyPlaces("User1", 55.75396, 37.620393, 20000, 1)
+	assert.NoError(t, err)
+	assert.Len(t, nearby, 1)
+
+	home.Label = "Old home"
+	home.CreatedAt = home.CreatedAt.AddDate(-1, 0, 0)
+	updated, err := serv.UpdatePlace("User1", home)
+	assert.NoError(t, err)
+	assert.Equal(t, "Old home", updated.Label)
+	got, err := serv.GetPlace("User1", home.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, got)
+	assert.NotEqual(t, home.CreatedAt, got.CreatedAt)
+	_, err = serv.UpdatePlace("User1", storage.Place{ID: "unknown", Address: storage.Address{Address: "г Москва"}, Label: "Home"})
+	assert.ErrorIs(t, err, storage.ErrPlaceNotFound)
+
+	assert.NoError(t, serv.DeletePlace("User1", home.ID))
+	_, err = serv.GetPlace("User1", home.ID)
+	assert.ErrorIs(t, err, storage.ErrPlaceNotFound)
+	assert.ErrorIs(t, serv.DeletePlace("User1", home.ID), storage.ErrPlaceNotFound)
+}
+
+func TestGeoService_CreatePlaceValidation(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	_, err := serv.Register("User1", "qwerty")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		place storage.Place
+		want  []string
+	}{
+		{"1", storage.Place{}, []string{"label required", "address.address required"}},
+		{"2", storage.Place{Address: storage.Address{Address: "г Москва", Lat: 91, Lon: -181}, Label: "Home"}, []string{"address.lat range", "address.lon range"}},
+		{"3", storage.Place{Address: storage.Address{Address: "г Москва"}, Label: "Home", Tags: []string{"family", " work"}}, []string{"tags format"}},
+		{"4", storage.Place{Address: storage.Address{Address: "г Москва"}, Label: "  "}, []string{"label required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := serv.CreatePlace("User1", tt.place)
+			var invalid *ValidationError
+			assert.True(t, errors.As(err, &invalid))
+			got := []string{}
+			for _, fe := range invalid.Errors {
+				got = append(got, fe.Field+" "+fe.Rule)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+
+	"go.uber.org/zap"
+)
+
+// StatusError is returned by providers when the upstream API answers with an
+// unexpected HTTP status.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error status %v %s", e.StatusCode, e.Provider)
+}
+
+// RetryingProvider decorates a GeocodingProvider with retries on transient
+// errors, waiting with exponential backoff and jitter between attempts.
+type RetryingProvider struct {
+	next      GeocodingProvider
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	jitter    float64
+	retryable map[int]bool
+	log       *zap.Logger
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func NewRetryingProvider(next GeocodingProvider, conf config.Retry, logger *zap.Logger) GeocodingProvider {
+	retryable := make(map[int]bool, len(conf.RetryableStatus))
+	for _, code := range conf.RetryableStatus {
+		retryable[code] = true
+	}
+	jitter := math.Max(0, math.Min(conf.Jitter, 1))
+
+	return &RetryingProvider{
+		next:      next,
+		attempts:  conf.MaxAttempts,
+		baseDelay: conf.BaseDelay,
+		maxDelay:  conf.MaxDelay,
+		jitter:    jitter,
+		retryable: retryable,
+		log:       logger,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (p *RetryingProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	return p.do(ctx, "search", func() ([]*responder.Address, error) {
+		return p.next.Search(ctx, query)
+	})
+}
+
+func (p *RetryingProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	return p.do(ctx, "geocode", func() ([]*responder.Address, error) {
+		return p.next.Geocode(ctx, lat, lon)
+	})
+}
+
+func (p *RetryingProvider) do(ctx context.Context, method string, fn func() ([]*responder.Address, error)) ([]*responder.Address, error) {
+	for attempt := 1; ; attempt++ {
+		addresses, err := fn()
+		if err == nil || attempt >= p.attempts || !p.isRetryable(ctx, err) {
+			return addresses, err
+		}
+
+		delay := p.backoff(attempt)
+		// no point in waiting if the caller gives up before the next attempt
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return addresses, err
+		}
+
+		p.log.Warn("retrying geocoding provider call",
+			zap.String("method", method),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w, last error: %v", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// isRetryable retries network errors, timeouts of a single attempt and the
+// configured status codes, but never once the caller's context is done.
+func (p *RetryingProvider) isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return p.retryable[statusErr.StatusCode]
+	}
+	return IsTimeout(err) || isNetworkError(err)
+}
+
+// backoff returns baseDelay * 2^(attempt-1), capped at maxDelay and spread by
+// up to ±jitter of its value.
+func (p *RetryingProvider) backoff(attempt int) time.Duration {
+	delay := float64(p.baseDelay) * math.Pow(2, float64(attempt-1))
+	if p.maxDelay > 0 {
+		delay = math.Min(delay, float64(p.maxDelay))
+	}
+
+	p.mu.Lock()
+	r := p.rand.Float64()
+	p.mu.Unlock()
+
+	return time.Duration(delay * (1 + p.jitter*(2*r-1)))
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"test/proxy/internal/config"
+	"test/proxy/internal/responder"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// flakyProvider fails with errs in order and succeeds once they run out
+type flakyProvider struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (p *flakyProvider) next() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls <= len(p.errs) {
+		return p.errs[p.calls-1]
+	}
+	return nil
+}
+
+func (p *flakyProvider) Search(ctx context.Context, query string) ([]*responder.Address, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return []*responder.Address{{Address: query}}, nil
+}
+
+func (p *flakyProvider) Geocode(ctx context.Context, lat, lon string) ([]*responder.Address, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return []*responder.Address{{Address: lat + "," + lon}}, nil
+}
+
+var testRetry = config.Retry{
+	MaxAttempts:     3,
+	BaseDelay:       time.Millisecond,
+	MaxDelay:        5 * time.Millisecond,
+	Jitter:          0.2,
+	RetryableStatus: []int{500, 502, 503, 504},
+}
+
+func TestRetryingProvider(t *testing.T) {
+	status := func(code int) error {
+		return &StatusError{Provider: "test", StatusCode: code}
+	}
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   bool
+		wantCalls int
+	}{
+		{"1", nil, false, 1},
+		{"2", []error{status(503)}, false, 2},
+		{"3", []error{status(502), status(504)}, false, 3},
+		{"4", []error{status(500), status(500), status(500)}, true, 3},
+		{"5", []error{status(400)}, true, 1},
+		{"6", []error{status(403), status(500)}, true, 1},
+		{"7", []error{netErr}, false, 2},
+		{"8", []error{context.DeadlineExceeded}, false, 2},
+		{"9", []error{errors.New("decode error")}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &flakyProvider{errs: tt.errs}
+			p := NewRetryingProvider(provider, testRetry, zap.NewNop())
+
+			got, err := p.Search(context.Background(), "Сухонская 11")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "Сухонская 11", got[0].Address)
+			}
+			assert.Equal(t, tt.wantCalls, provider.calls)
+		})
+	}
+}
+
+func TestRetryingProvider_Context(t *testing.T) {
+	errs := []error{&StatusError{StatusCode: 503}, &StatusError{StatusCode: 503}}
+
+	// the deadline is closer than the first backoff delay
+	provider := &flakyProvider{errs: errs}
+	conf := testRetry
+	conf.BaseDelay, conf.MaxDelay = time.Second, time.Second
+	p := NewRetryingProvider(provider, conf, zap.NewNop())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := p.Geocode(ctx, "55.878", "37.653")
+	assert.Error(t, err)
+	assert.Equal(t, 1, provider.calls)
+
+	// the caller gives up while waiting for the next attempt
+	provider = &flakyProvider{errs: errs}
+	p = NewRetryingProvider(provider, conf, zap.NewNop())
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = p.Geocode(ctx, "55.878", "37.653")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), conf.BaseDelay)
+	assert.Equal(t, 1, provider.calls)
+}
+
+func TestRetryingProvider_Backoff(t *testing.T) {
+	p := NewRetryingProvider(nil, config.Retry{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+		Jitter:    0.5,
+	}, zap.NewNop()).(*RetryingProvider)
+
+	tests := []struct {
+		name    string
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"1", 1, 50 * time.Millisecond, 150 * time.Millisecond},
+		{"2", 2, 100 * time.Millisecond, 300 * time.Millisecond},
+		{"3", 3, 200 * time.Millisecond, 600 * time.Millisecond},
+		{"4", 5, 500 * time.Millisecond, 1500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				delay := p.backoff(tt.attempt)
+				assert.GreaterOrEqual(t, delay, tt.min)
+				assert.LessOrEqual(t, delay, tt.max)
+			}
+		})
+	}
+}
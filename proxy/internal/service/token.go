@@ -0,0 +1,215 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"test/proxy/internal/storage"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const (
+	// TokenTypeClaim tells access tokens apart from refresh tokens
+	TokenTypeClaim = "type"
+	// RolesClaim lists the user roles at the time the token was issued
+	RolesClaim = "roles"
+	// EpochClaim is the token epoch of the user at the time the token was
+	// issued, tokens of an older epoch are revoked
+	EpochClaim       = "epoch"
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Tokens struct {
+	// Login is the user the tokens were issued to
+	Login        string
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// issueTokens creates a short-lived access token and a long-lived refresh
+// token. Both carry only identity claims and the user roles.
+func (g *GeoService) issueTokens(user storage.User) (*Tokens, error) {
+	now := time.Now()
+
+	accessToken, err := g.encodeToken(user, AccessTokenType, now, g.tokenAuth.AccessTTL())
+	if err != nil {
+		return nil, fmt.Errorf("error encode access token: %v", err)
+	}
+	refreshToken, err := g.encodeToken(user, RefreshTokenType, now, g.tokenAuth.RefreshTTL())
+	if err != nil {
+		return nil, fmt.Errorf("error encode refresh token: %v", err)
+	}
+
+	return &Tokens{
+		Login:        user.Login,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    g.tokenAuth.AccessTTL(),
+	}, nil
+}
+
+func (g *GeoService) encodeToken(user storage.User, tokenType string, now time.Time, ttl time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	_, tokenString, err := g.tokenAuth.Encode(map[string]interface{}{
+		jwt.SubjectKey:    user.Login,
+		jwt.IssuedAtKey:   now.Unix(),
+		jwt.ExpirationKey: now.Add(ttl).Unix(),
+		jwt.JwtIDKey:      jti,
+		TokenTypeClaim:    tokenType,
+		RolesClaim:        user.Roles,
+		EpochClaim:        user.TokenEpoch,
+	})
+	return tokenString, err
+}
+
+// Refresh exchanges a valid refresh token for a new token pair.
+func (g *GeoService) Refresh(refreshToken string) (*Tokens, error) {
+	token, err := g.tokenAuth.Decode(refreshToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := jwt.Validate(token, g.tokenAuth.ValidateOptions()...); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if tokenType, _ := token.Get(TokenTypeClaim); tokenType != RefreshTokenType {
+		return nil, ErrInvalidToken
+	}
+	// roles are read again, so role changes apply from the next refresh
+	user, err := g.users.Get(token.Subject())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	revoked, err := g.IsRevoked(token)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	// a refresh token is single use, the client gets a new one with the pair
+	if err := g.revoke(token); err != nil {
+		return nil, err
+	}
+
+	return g.issueTokens(user)
+}
+
+// Logout revokes the access token of the current session and, if given, the
+// refresh token issued with it.
+func (g *GeoService) Logout(accessToken jwt.Token, refreshToken string) error {
+	if refreshToken != "" {
+		token, err := g.decodeToken(refreshToken)
+		if err != nil || token.Subject() != accessToken.Subject() {
+			return ErrInvalidToken
+		}
+		if err := g.revoke(token); err != nil {
+			return err
+		}
+	}
+	return g.revoke(accessToken)
+}
+
+// RevokeToken puts any token signed by us on the denylist, whoever owns it.
+func (g *GeoService) RevokeToken(tokenString string) error {
+	token, err := g.decodeToken(tokenString)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	return g.revoke(token)
+}
+
+// IsRevoked reports whether the token is on the denylist, was issued before
+// its user changed the password or deleted the account, or its user is
+// disabled.
+func (g *GeoService) IsRevoked(token jwt.Token) (bool, error) {
+	revoked, err := g.revoked.IsRevoked(token.JwtID())
+	if err != nil {
+		return false, fmt.Errorf("error check token revocation: %v", err)
+	}
+	if revoked {
+		return true, nil
+	}
+
+	// tokens without the claim were not issued by GeoService
+	claim, ok := token.Get(EpochClaim)
+	if !ok {
+		return false, nil
+	}
+	epoch, _ := claim.(string)
+	user, err := g.users.Get(token.Subject())
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error check token revocation: %v", err)
+	}
+	return epoch != user.TokenEpoch || user.Disabled, nil
+}
+
+// decodeToken verifies the signature only, revoking an already expired token
+// is a harmless no-op.
+func (g *GeoService) decodeToken(tokenString string) (jwt.Token, error) {
+	token, err := g.tokenAuth.Decode(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if token.JwtID() == "" {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
+
+func (g *GeoService) revoke(token jwt.Token) error {
+	if token.JwtID() == "" {
+		return ErrInvalidToken
+	}
+	if err := g.revoked.Revoke(token.JwtID(), token.Expiration()); err != nil {
+		return fmt.Errorf("error revoke token: %v", err)
+	}
+	return nil
+}
+
+// TokenRoles returns the roles embedded in the token.
+func TokenRoles(token jwt.Token) []string {
+	return claimStrings(token, RolesClaim)
+}
+
+// claimStrings reads a list claim, which is []string in tokens built in
+// memory and []interface{} in decoded ones.
+func claimStrings(token jwt.Token, name string) []string {
+	claim, _ := token.Get(name)
+
+	var list []string
+	switch v := claim.(type) {
+	case []string:
+		list = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
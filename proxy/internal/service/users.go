@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"test/proxy/internal/storage"
+)
+
+var (
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrUnknownRole     = errors.New("unknown role")
+	// ErrSelfLockout is returned when an admin would disable, delete or
+	// demote themselves
+	ErrSelfLockout = errors.New("admins cannot lock themselves out")
+
+	knownRoles = map[string]bool{storage.RoleUser: true, storage.RoleAdmin: true, storage.RoleBatch: true}
+)
+
+// UserPage is a page of users matching a search, Total counts every match.
+type UserPage struct {
+	Users []storage.User
+	Total int
+}
+
+// ListUsers returns up to limit users, skipping the first offset, whose login
+// contains query case-insensitively. An empty query matches every user.
+func (g *GeoService) ListUsers(query string, offset, limit int) (*UserPage, error) {
+	users, err := g.users.List()
+	if err != nil {
+		return nil, fmt.Errorf("error list users: %v", err)
+	}
+
+	query = strings.ToLower(query)
+	matched := users[:0]
+	for _, user := range users {
+		if strings.Contains(strings.ToLower(user.Login), query) {
+			matched = append(matched, user)
+		}
+	}
+
+	page := &UserPage{Users: []storage.User{}, Total: len(matched)}
+	if offset < len(matched) {
+		end := len(matched)
+		if limit > 0 && offset+limit < end {
+			end = offset + limit
+		}
+		page.Users = matched[offset:end]
+	}
+	return page, nil
+}
+
+func (g *GeoService) GetUser(login string) (storage.User, error) {
+	user, err := g.users.Get(login)
+	if err != nil {
+		return storage.User{}, fmt.Errorf("error get user %s: %w", login, err)
+	}
+	return user, nil
+}
+
+// SetUserDisabled disables or re-enables login on behalf of admin. Tokens
+// issued before the account was disabled stay revoked when it is enabled
+// again.
+func (g *GeoService) SetUserDisabled(admin, login string, disabled bool) error {
+	if disabled && admin == login {
+		return ErrSelfLockout
+	}
+
+	epoch, err := newTokenID()
+	if err != nil {
+		return fmt.Errorf("error update user %s: %v", login, err)
+	}
+	if err := g.users.SetDisabled(login, disabled, epoch); err != nil {
+		return fmt.Errorf("error update user %s: %w", login, err)
+	}
+	return nil
+}
+
+// SetUserRoles replaces the roles of login on behalf of admin. A change of
+// the roles revokes every token issued before, so no token outlives the
+// roles embedded in it.
+func (g *GeoService) SetUserRoles(admin, login string, roles []string) error {
+	for _, role := range roles {
+		if !knownRoles[role] {
+			return fmt.Errorf("%w: %q", ErrUnknownRole, role)
+		}
+	}
+	if admin == login && !(storage.User{Roles: roles}).HasRole(storage.RoleAdmin) {
+		return ErrSelfLockout
+	}
+
+	epoch, err := newTokenID()
+	if err != nil {
+		return fmt.Errorf("error update user %s: %v", login, err)
+	}
+	if err := g.users.SetRoles(login, roles, epoch); err != nil {
+		return fmt.Errorf("error update user %s: %w", login, err)
+	}
+	return nil
+}
+
+// DeleteUser removes login and its API keys on behalf of admin.
+func (g *GeoService) DeleteUser(admin, login string) error {
+	if admin == login {
+		return ErrSelfLockout
+	}
+	return g.deleteUser(login)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"testing"
+
+	"test/proxy/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoService_ListUsers(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords)
+	for _, login := range []string{"User3", "Admin", "User1", "User2"} {
+		_, err := serv.Register(login, "qwerty")
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		offset    int
+		limit     int
+		want      []string
+		wantTotal int
+	}{
+		{"1", "", 0, 10, []string{"Admin", "User1", "User2", "User3"}, 4},
+		{"2", "user", 0, 2, []string{"User1", "User2"}, 3},
+		{"3", "user", 2, 2, []string{"User3"}, 3},
+		{"4", "user", 5, 2, []string{}, 3},
+		{"5", "nobody", 0, 10, []string{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := serv.ListUsers(tt.query, tt.offset, tt.limit)
+			assert.NoError(t, err)
+			logins := []string{}
+			for _, user := range page.Users {
+				logins = append(logins, user.Login)
+			}
+			assert.Equal(t, tt.want, logins)
+			assert.Equal(t, tt.wantTotal, page.Total)
+		})
+	}
+}
+
+func TestGeoService_SetUserDisabled(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords, "Admin")
+	_, _ = serv.Register("Admin", "qwerty")
+	tokens, _ := serv.Register("User1", "qwerty")
+	issued, _ := serv.CreateAPIKey("User1", "import", nil, 0)
+	access, _ := testTokenAuth.Decode(tokens.AccessToken)
+
+	assert.ErrorIs(t, serv.SetUserDisabled("Admin", "Admin", true), ErrSelfLockout)
+	assert.ErrorIs(t, serv.SetUserDisabled("Admin", "Unknown", true), storage.ErrUserNotFound)
+
+	assert.NoError(t, serv.SetUserDisabled("Admin", "User1", true))
+	revoked, _ := serv.IsRevoked(access)
+	assert.True(t, revoked)
+	_, err := serv.Login("User1", "qwerty", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+	_, err = serv.Login("User1", "wrong", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = serv.AuthenticateAPIKey(issued.Key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	// enabling the user again does not bring back the old tokens
+	assert.NoError(t, serv.SetUserDisabled("Admin", "User1", false))
+	revoked, _ = serv.IsRevoked(access)
+	assert.True(t, revoked)
+	_, err = serv.Refresh(tokens.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = serv.Login("User1", "qwerty", "192.0.2.1")
+	assert.NoError(t, err)
+	_, err = serv.AuthenticateAPIKey(issued.Key)
+	assert.NoError(t, err)
+}
+
+func TestGeoService_SetUserRoles(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords, "Admin")
+	_, _ = serv.Register("Admin", "qwerty")
+	tokens, _ := serv.Register("User1", "qwerty")
+
+	tests := []struct {
+		name    string
+		login   string
+		roles   []string
+		wantErr error
+	}{
+		{"1", "User1", []string{storage.RoleUser, "root"}, ErrUnknownRole},
+		{"2", "Admin", []string{storage.RoleUser}, ErrSelfLockout},
+		{"3", "Unknown", []string{storage.RoleUser}, storage.ErrUserNotFound},
+		{"4", "User1", []string{storage.RoleUser, storage.RoleBatch}, nil},
+		{"5", "Admin", []string{storage.RoleAdmin}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := serv.SetUserRoles("Admin", tt.login, tt.roles)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			user, _ := serv.GetUser(tt.login)
+			assert.Equal(t, tt.roles, user.Roles)
+		})
+	}
+
+	// tokens issued with the old roles are revoked
+	access, _ := testTokenAuth.Decode(tokens.AccessToken)
+	revoked, _ := serv.IsRevoked(access)
+	assert.True(t, revoked)
+	_, err := serv.Refresh(tokens.RefreshToken)
+	assert.Error(t, err)
+}
+
+func TestGeoService_DeleteUser(t *testing.T) {
+	serv := NewGeoService(&stubProvider{}, storage.NewMemoryUserRepository(), storage.NewMemoryRevocationStore(), storage.NewMemoryAPIKeyRepository(), testTokenAuth, testPasswords, "Admin")
+	_, _ = serv.Register("Admin", "qwerty")
+	tokens, _ := serv.Register("User1", "qwerty")
+
+	assert.ErrorIs(t, serv.DeleteUser("Admin", "Admin"), ErrSelfLockout)
+	assert.ErrorIs(t, serv.DeleteUser("Admin", "Unknown"), storage.ErrUserNotFound)
+	assert.NoError(t, serv.DeleteUser("Admin", "User1"))
+	assert.False(t, serv.IsUserExist("User1"))
+	access, _ := testTokenAuth.Decode(tokens.AccessToken)
+	revoked, _ := serv.IsRevoked(access)
+	assert.True(t, revoked)
+}
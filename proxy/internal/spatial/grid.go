@@ -0,0 +1,163 @@
+package spatial
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	earthRadius = 6371008.8
+	// metersPerDegree is the length of one degree of latitude
+	metersPerDegree = earthRadius * math.Pi / 180
+)
+
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+type Neighbor struct {
+	ID       int
+	Distance float64
+}
+
+type cellKey struct {
+	lat int
+	lon int
+}
+
+// Grid is an in-memory spatial index that buckets points into fixed-size
+// latitude/longitude cells. Only cells overlapping the search radius are
+// scanned on lookup.
+type Grid struct {
+	cellSize float64
+	lonCells int
+	cells    map[cellKey][]int
+	points   []Point
+}
+
+// NewGrid creates an empty index with square cells of cellSize degrees.
+func NewGrid(cellSize float64) *Grid {
+	if cellSize <= 0 || cellSize > 180 {
+		cellSize = 0.01
+	}
+	// round the cell size so that longitude cells wrap evenly at the antimeridian
+	lonCells := int(math.Ceil(360 / cellSize))
+	return &Grid{
+		cellSize: 360 / float64(lonCells),
+		lonCells: lonCells,
+		cells:    make(map[cellKey][]int),
+	}
+}
+
+// Insert adds a point and returns its ID, which is the insertion order.
+func (g *Grid) Insert(p Point) int {
+	id := len(g.points)
+	g.points = append(g.points, p)
+	key := g.key(p)
+	g.cells[key] = append(g.cells[key], id)
+	return id
+}
+
+func (g *Grid) Len() int {
+	return len(g.points)
+}
+
+// Nearest returns up to k points within radius meters of p, closest first.
+// A non-positive k returns all points in the radius, a non-positive radius
+// disables the distance limit.
+func (g *Grid) Nearest(p Point, k int, radius float64) []Neighbor {
+	var candidates []int
+	if ids, ok := g.candidates(p, radius); ok {
+		candidates = ids
+	} else {
+		candidates = make([]int, len(g.points))
+		for i := range g.points {
+			candidates[i] = i
+		}
+	}
+
+	neighbors := make([]Neighbor, 0, len(candidates))
+	for _, id := range candidates {
+		d := Distance(p, g.points[id])
+		if radius > 0 && d > radius {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{ID: id, Distance: d})
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].Distance == neighbors[j].Distance {
+			return neighbors[i].ID < neighbors[j].ID
+		}
+		return neighbors[i].Distance < neighbors[j].Distance
+	})
+	if k > 0 && len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+
+	return neighbors
+}
+
+// candidates collects point IDs from the cells covering the bounding box of
+// the search circle. It reports false when scanning every point is cheaper.
+func (g *Grid) candidates(p Point, radius float64) ([]int, bool) {
+	if radius <= 0 {
+		return nil, false
+	}
+
+	latDelta := radius / metersPerDegree
+	minLat := math.Max(p.Lat-latDelta, -90)
+	maxLat := math.Min(p.Lat+latDelta, 90)
+
+	// the box is widest in longitude at the latitude closest to a pole
+	cosLat := math.Cos(math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180)
+	lonDelta := 180.0
+	if cosLat > 1e-9 {
+		lonDelta = math.Min(latDelta/cosLat, 180)
+	}
+
+	minLatCell := g.cell(minLat)
+	maxLatCell := g.cell(maxLat)
+	minLonCell := g.cell(p.Lon - lonDelta)
+	maxLonCell := g.cell(p.Lon + lonDelta)
+	if maxLonCell-minLonCell+1 > g.lonCells {
+		minLonCell, maxLonCell = 0, g.lonCells-1
+	}
+
+	cellCount := (maxLatCell - minLatCell + 1) * (maxLonCell - minLonCell + 1)
+	if cellCount > len(g.points) {
+		return nil, false
+	}
+
+	var ids []int
+	for lat := minLatCell; lat <= maxLatCell; lat++ {
+		for lon := minLonCell; lon <= maxLonCell; lon++ {
+			ids = append(ids, g.cells[cellKey{lat: lat, lon: g.wrapLon(lon)}]...)
+		}
+	}
+	return ids, true
+}
+
+func (g *Grid) key(p Point) cellKey {
+	return cellKey{lat: g.cell(p.Lat), lon: g.wrapLon(g.cell(p.Lon))}
+}
+
+func (g *Grid) cell(deg float64) int {
+	return int(math.Floor(deg / g.cellSize))
+}
+
+func (g *Grid) wrapLon(cell int) int {
+	return ((cell % g.lonCells) + g.lonCells) % g.lonCells
+}
+
+// Distance returns the great-circle distance between two points in meters.
+func Distance(a, b Point) float64 {
+	phi1 := a.Lat * math.Pi / 180
+	phi2 := b.Lat * math.Pi / 180
+	dPhi := (b.Lat - a.Lat) * math.Pi / 180
+	dLambda := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
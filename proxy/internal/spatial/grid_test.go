@@ -0,0 +1,88 @@
+package spatial
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Point
+		b    Point
+		want float64
+	}{
+		{"1", Point{55.7522, 37.6156}, Point{55.7522, 37.6156}, 0},
+		{"2", Point{55.7522, 37.6156}, Point{59.9386, 30.3141}, 634_000},
+		{"3", Point{0, 179.9}, Point{0, -179.9}, 22_239},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Distance(tt.a, tt.b), tt.want*0.01+1)
+		})
+	}
+}
+
+func TestGrid_Nearest(t *testing.T) {
+	grid := NewGrid(0.01)
+	grid.Insert(Point{55.878315, 37.65372})  // 0
+	grid.Insert(Point{55.878666, 37.6524})   // 1
+	grid.Insert(Point{55.877167, 37.652481}) // 2
+	grid.Insert(Point{59.851461, 30.264849}) // 3
+	grid.Insert(Point{0.0001, 179.9999})     // 4
+	grid.Insert(Point{0.0001, -179.9999})    // 5
+
+	tests := []struct {
+		name   string
+		p      Point
+		k      int
+		radius float64
+		want   []int
+	}{
+		{"1", Point{55.8786, 37.6525}, 2, 1000, []int{1, 0}},
+		{"2", Point{55.8786, 37.6525}, 0, 1000, []int{1, 0, 2}},
+		{"3", Point{55.8786, 37.6525}, 1, 0, []int{1}},
+		{"4", Point{59.9398, 30.2604}, 5, 1000, []int{}},
+		{"5", Point{0, -179.99995}, 0, 1000, []int{5, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := grid.Nearest(tt.p, tt.k, tt.radius)
+			ids := make([]int, len(got))
+			for i, n := range got {
+				ids[i] = n.ID
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestGrid_NearestMatchesLinearScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	grid := NewGrid(0.05)
+	points := make([]Point, 2000)
+	for i := range points {
+		points[i] = Point{Lat: 55 + rnd.Float64(), Lon: 37 + rnd.Float64()}
+		grid.Insert(points[i])
+	}
+
+	for i := 0; i < 50; i++ {
+		p := Point{Lat: 55 + rnd.Float64(), Lon: 37 + rnd.Float64()}
+
+		want := make([]Neighbor, 0)
+		for id, q := range points {
+			if d := Distance(p, q); d <= 3000 {
+				want = append(want, Neighbor{ID: id, Distance: d})
+			}
+		}
+		sort.Slice(want, func(i, j int) bool { return want[i].Distance < want[j].Distance })
+		if len(want) > 10 {
+			want = want[:10]
+		}
+
+		assert.Equal(t, want, grid.Nearest(p, 10, 3000))
+	}
+}
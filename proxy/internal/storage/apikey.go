@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyExists   = errors.New("api key already exists")
+)
+
+// APIKey is a long-lived credential of a user. Only the hash of the key is
+// stored, the key itself is shown once when it is issued.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Login  string   `json:"login"`
+	Hash   string   `json:"hash"`
+	Name   string   `json:"name,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresAt is zero for keys that never expire
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (k APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// APIKeyRepository stores issued API keys. Create fails with ErrAPIKeyExists
+// when the ID or hash is taken. Implementations must be safe for concurrent
+// use.
+type APIKeyRepository interface {
+	Create(key APIKey) error
+	GetByHash(hash string) (APIKey, error)
+	List(login string) ([]APIKey, error)
+	Delete(login, id string) error
+}
+
+type MemoryAPIKeyRepository struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+	// byHash maps key hashes to IDs, every request with a key looks it up
+	byHash map[string]string
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{keys: make(map[string]APIKey), byHash: make(map[string]string)}
+}
+
+func hashIndex(keys map[string]APIKey) map[string]string {
+	byHash := make(map[string]string, len(keys))
+	for id, key := range keys {
+		byHash[key.Hash] = id
+	}
+	return byHash
+}
+
+func (m *MemoryAPIKeyRepository) Create(key APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.add(key)
+}
+
+func (m *MemoryAPIKeyRepository) GetByHash(hash string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byHash[hash]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return m.keys[id], nil
+}
+
+// List returns the keys of the user, oldest first.
+func (m *MemoryAPIKeyRepository) List(login string) ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]APIKey, 0)
+	for _, key := range m.keys {
+		if key.Login == login {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (m *MemoryAPIKeyRepository) Delete(login, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.keys[id]; !ok || key.Login != login {
+		return ErrAPIKeyNotFound
+	}
+	m.remove(id)
+	return nil
+}
+
+// add stores a new key, the caller must hold the lock.
+func (m *MemoryAPIKeyRepository) add(key APIKey) error {
+	if _, ok := m.keys[key.ID]; ok {
+		return ErrAPIKeyExists
+	}
+	if _, ok := m.byHash[key.Hash]; ok {
+		return ErrAPIKeyExists
+	}
+	m.keys[key.ID] = key
+	m.byHash[key.Hash] = key.ID
+	return nil
+}
+
+// remove drops the key with id, the caller must hold the lock.
+func (m *MemoryAPIKeyRepository) remove(id string) {
+	delete(m.byHash, m.keys[id].Hash)
+	delete(m.keys, id)
+}
@@ -0,0 +1,49 @@
+package storage
+
+// FileAPIKeyRepository keeps API keys in memory and persists every change to
+// a JSON file.
+type FileAPIKeyRepository struct {
+	path string
+	MemoryAPIKeyRepository
+}
+
+func NewFileAPIKeyRepository(path string) (*FileAPIKeyRepository, error) {
+	keys := make(map[string]APIKey)
+	if err := readJSONFile(path, &keys); err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = make(map[string]APIKey)
+	}
+	return &FileAPIKeyRepository{path: path, MemoryAPIKeyRepository: MemoryAPIKeyRepository{keys: keys, byHash: hashIndex(keys)}}, nil
+}
+
+func (f *FileAPIKeyRepository) Create(key APIKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.add(key); err != nil {
+		return err
+	}
+	if err := writeJSONFile(f.path, f.keys); err != nil {
+		f.remove(key.ID)
+		return err
+	}
+	return nil
+}
+
+func (f *FileAPIKeyRepository) Delete(login, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old, ok := f.keys[id]
+	if !ok || old.Login != login {
+		return ErrAPIKeyNotFound
+	}
+	f.remove(id)
+	if err := writeJSONFile(f.path, f.keys); err != nil {
+		_ = f.add(old)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyRepository(t *testing.T) {
+	fileRepo, err := NewFileAPIKeyRepository(filepath.Join(t.TempDir(), "apikeys.json"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		repo APIKeyRepository
+	}{
+		{"memory", NewMemoryAPIKeyRepository()},
+		{"file", fileRepo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			assert.NoError(t, tt.repo.Create(APIKey{ID: "k2", Login: "User1", Hash: "hash2", CreatedAt: now.Add(time.Second)}))
+			assert.NoError(t, tt.repo.Create(APIKey{ID: "k1", Login: "User1", Hash: "hash1", CreatedAt: now}))
+			assert.NoError(t, tt.repo.Create(APIKey{ID: "k3", Login: "User2", Hash: "hash3", CreatedAt: now}))
+			// a taken ID or hash does not replace the key
+			assert.ErrorIs(t, tt.repo.Create(APIKey{ID: "k2", Login: "User2", Hash: "hash4"}), ErrAPIKeyExists)
+			assert.ErrorIs(t, tt.repo.Create(APIKey{ID: "k4", Login: "User2", Hash: "hash2"}), ErrAPIKeyExists)
+
+			key, err := tt.repo.GetByHash("hash2")
+			assert.NoError(t, err)
+			assert.Equal(t, "k2", key.ID)
+			_, err = tt.repo.GetByHash("hash4")
+			assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+			keys, err := tt.repo.List("User1")
+			assert.NoError(t, err)
+			assert.Len(t, keys, 2)
+			assert.Equal(t, "k1", keys[0].ID)
+
+			assert.ErrorIs(t, tt.repo.Delete("User2", "k1"), ErrAPIKeyNotFound)
+			assert.NoError(t, tt.repo.Delete("User1", "k1"))
+			assert.ErrorIs(t, tt.repo.Delete("User1", "k1"), ErrAPIKeyNotFound)
+			keys, _ = tt.repo.List("User1")
+			assert.Len(t, keys, 1)
+			_, err = tt.repo.GetByHash("hash1")
+			assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+		})
+	}
+}
+
+func TestAPIKey_Expired(t *testing.T) {
+	now := time.Now()
+	assert.False(t, APIKey{}.Expired(now))
+	assert.False(t, APIKey{ExpiresAt: now.Add(time.Minute)}.Expired(now))
+	assert.True(t, APIKey{ExpiresAt: now}.Expired(now))
+}
+
+func TestFileAPIKeyRepository_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "apikeys.json")
+
+	repo, err := NewFileAPIKeyRepository(path)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Create(APIKey{ID: "k1", Login: "User1", Hash: "hash1", Scopes: []string{"address:search"}}))
+	assert.NoError(t, repo.Create(APIKey{ID: "k2", Login: "User1", Hash: "hash2"}))
+	assert.NoError(t, repo.Delete("User1", "k2"))
+
+	reopened, err := NewFileAPIKeyRepository(path)
+	assert.NoError(t, err)
+	key, err := reopened.GetByHash("hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"address:search"}, key.Scopes)
+	_, err = reopened.GetByHash("hash2")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
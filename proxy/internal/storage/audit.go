@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// AuditEvent records who did what. Events are only ever appended.
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	// Actor is the login of the caller, empty for anonymous requests
+	Actor  string `json:"actor,omitempty"`
+	Action string `json:"action"`
+	// Target is what the action was applied to, e.g. a login
+	Target string `json:"target,omitempty"`
+	IP     string `json:"ip,omitempty"`
+	// Status is the HTTP status the action was answered with
+	Status int `json:"status,omitempty"`
+	// Query is the looked up address or coordinates of geocoding requests
+	Query string `json:"query,omitempty"`
+	// Results is the number of addresses found, nil when nothing was looked up
+	Results *int `json:"results,omitempty"`
+	// LatencyMs is how long the request took in milliseconds
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+}
+
+// AuditFilter selects events. Zero fields match every event.
+type AuditFilter struct {
+	// From and To bound the event time, both inclusive
+	From  time.Time
+	To    time.Time
+	Actor string
+	// Limit caps the number of events returned, the most recent are kept
+	Limit int
+}
+
+func (f AuditFilter) Match(event AuditEvent) bool {
+	if !f.From.IsZero() && event.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && event.Time.After(f.To) {
+		return false
+	}
+	return f.Actor == "" || event.Actor == f.Actor
+}
+
+// AuditLog is the append-only audit trail. Implementations must be safe for
+// concurrent use.
+type AuditLog interface {
+	Append(event AuditEvent) error
+	// Query returns the events matching filter, most recent first
+	Query(filter AuditFilter) ([]AuditEvent, error)
+}
+
+type MemoryAuditLog struct {
+	mu     sync.RWMutex
+	events []AuditEvent
+}
+
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+func (m *MemoryAuditLog) Append(event AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *MemoryAuditLog) Query(filter AuditFilter) ([]AuditEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return queryAuditEvents(m.events, filter), nil
+}
+
+// Events returns the recorded events, oldest first.
+func (m *MemoryAuditLog) Events() []AuditEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]AuditEvent(nil), m.events...)
+}
+
+// queryAuditEvents filters events recorded oldest first and returns the
+// matches most recent first.
+func queryAuditEvents(events []AuditEvent, filter AuditFilter) []AuditEvent {
+	matched := make([]AuditEvent, 0)
+	for i := len(events) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(matched) == filter.Limit {
+			break
+		}
+		if filter.Match(events[i]) {
+			matched = append(matched, events[i])
+		}
+	}
+	return matched
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// rotatedSuffix is the time format appended to the name of rotated files, it
+// sorts in the order the files were rotated.
+const rotatedSuffix = "20060102T150405.000000000"
+
+// FileAuditLog appends events to a file as JSON lines, one event per line.
+// A file growing over maxSize bytes is renamed with the rotation time as
+// suffix and a new one is started, only the last maxFiles rotated files are
+// kept.
+type FileAuditLog struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	// partial is set when the file ends in the middle of a line, left by a
+	// crash or a failed write, the next event starts on a new line
+	partial bool
+	now     func() time.Time
+	log     *zap.Logger
+}
+
+// NewFileAuditLog opens the log at path. maxSize 0 never rotates it,
+// maxFiles 0 keeps every rotated file.
+func NewFileAuditLog(path string, maxSize int64, maxFiles int, logger *zap.Logger) (*FileAuditLog, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error create dir %s: %v", dir, err)
+	}
+	f := &FileAuditLog{path: path, maxSize: maxSize, maxFiles: maxFiles, now: time.Now, log: logger}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileAuditLog) Append(event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encode audit event: %v", err)
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	if f.partial {
+		line = append([]byte{'\n'}, line...)
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if n > 0 {
+		f.partial = n < len(line)
+	}
+	if err != nil {
+		return fmt.Errorf("error write %s: %v", f.path, err)
+	}
+	return nil
+}
+
+// Query reads the current file and then the rotated ones, most recent event
+// first, and stops once filter.Limit events matched. It does not hold up
+// Append: an event still being written is skipped, and events of a file
+// rotated or pruned while reading may be missed, which is fine for the
+// occasional lookups by admins it is meant for. Lines that do not decode,
+// left by a crash while appending, are logged and skipped.
+func (f *FileAuditLog) Query(filter AuditFilter) ([]AuditEvent, error) {
+	rotated, err := f.rotated()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{f.path}
+	for i := len(rotated) - 1; i >= 0; i-- {
+		paths = append(paths, rotated[i])
+	}
+
+	events := make([]AuditEvent, 0)
+	for _, path := range paths {
+		err := readLinesReverse(path, func(line []byte) (bool, error) {
+			event := AuditEvent{}
+			if err := json.Unmarshal(line, &event); err != nil {
+				f.log.Warn("skipped broken audit line", zap.String("file", path), zap.ByteString("line", line), zap.Error(err))
+				return true, nil
+			}
+			if filter.Match(event) {
+				events = append(events, event)
+			}
+			return filter.Limit <= 0 || len(events) < filter.Limit, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if filter.Limit > 0 && len(events) >= filter.Limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func (f *FileAuditLog) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+func (f *FileAuditLog) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("error open %s: %v", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error stat %s: %v", f.path, err)
+	}
+	f.file, f.size, f.partial = file, info.Size(), false
+	if f.size > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, f.size-1); err != nil {
+			file.Close()
+			return fmt.Errorf("error read %s: %v", f.path, err)
+		}
+		f.partial = last[0] != '\n'
+	}
+	return nil
+}
+
+// rotate starts a new file, the caller must hold the lock.
+func (f *FileAuditLog) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("error close %s: %v", f.path, err)
+	}
+	rotatedPath := f.path + "." + f.now().UTC().Format(rotatedSuffix)
+	if err := os.Rename(f.path, rotatedPath); err != nil {
+		return fmt.Errorf("error rotate %s: %v", f.path, err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	if f.maxFiles <= 0 {
+		return nil
+	}
+	rotated, err := f.rotated()
+	if err != nil {
+		return err
+	}
+	for len(rotated) > f.maxFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			return fmt.Errorf("error remove %s: %v", rotated[0], err)
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// rotated lists the rotated files, oldest first.
+func (f *FileAuditLog) rotated() ([]string, error) {
+	paths, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("error list %s: %v", f.path, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// readLinesReverse calls fn with the lines of the file at path, last first,
+// until fn returns false. Bytes after the last newline belong to a line still
+// being written and are skipped. A missing file, pruned since it was listed,
+// has no lines.
+func readLinesReverse(path string, fn func(line []byte) (bool, error)) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error stat %s: %v", path, err)
+	}
+
+	const blockSize = 64 * 1024
+	var (
+		pos     = info.Size()
+		buf     []byte
+		partial = true
+	)
+	for {
+		// buf holds the bytes from pos up to the last line not yet passed
+		for {
+			i := bytes.LastIndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+			line := buf[i+1:]
+			buf = buf[:i]
+			if partial {
+				partial = false
+				continue
+			}
+			if len(line) == 0 {
+				continue
+			}
+			if more, err := fn(line); err != nil || !more {
+				return err
+			}
+		}
+		if pos == 0 {
+			break
+		}
+
+		n := int64(blockSize)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		block := make([]byte, n, n+int64(len(buf)))
+		if _, err := file.ReadAt(block, pos); err != nil {
+			return fmt.Errorf("error read %s: %v", path, err)
+		}
+		buf = append(block, buf...)
+	}
+
+	// the first line of the file, unless nothing ended with a newline
+	if partial || len(buf) == 0 {
+		return nil
+	}
+	_, err = fn(buf)
+	return err
+}
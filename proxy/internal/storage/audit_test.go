@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMemoryAuditLog(t *testing.T) {
+	log := NewMemoryAuditLog()
+	event := AuditEvent{Time: time.Unix(1700000000, 0), Actor: "Admin", Action: "DELETE /api/admin/users/{login}", Target: "User1", Status: 204}
+
+	assert.NoError(t, log.Append(event))
+	assert.Equal(t, []AuditEvent{event}, log.Events())
+}
+
+func TestMemoryAuditLog_Query(t *testing.T) {
+	log := NewMemoryAuditLog()
+	base := time.Unix(1700000000, 0)
+	for i, actor := range []string{"User1", "User2", "User1", "User1"} {
+		assert.NoError(t, log.Append(AuditEvent{Time: base.Add(time.Duration(i) * time.Minute), Actor: actor, Action: "login"}))
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []time.Time
+	}{
+		{"all", AuditFilter{}, []time.Time{base.Add(3 * time.Minute), base.Add(2 * time.Minute), base.Add(time.Minute), base}},
+		{"actor", AuditFilter{Actor: "User2"}, []time.Time{base.Add(time.Minute)}},
+		{"range", AuditFilter{From: base.Add(time.Minute), To: base.Add(2 * time.Minute)}, []time.Time{base.Add(2 * time.Minute), base.Add(time.Minute)}},
+		{"limit", AuditFilter{Actor: "User1", Limit: 2}, []time.Time{base.Add(3 * time.Minute), base.Add(2 * time.Minute)}},
+		{"none", AuditFilter{Actor: "User3"}, []time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := log.Query(tt.filter)
+			assert.NoError(t, err)
+			got := make([]time.Time, 0, len(events))
+			for _, event := range events {
+				got = append(got, event.Time)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFileAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "audit.log")
+	events := []AuditEvent{
+		{Time: time.Unix(1700000000, 0).UTC(), Actor: "Admin", Action: "GET /api/admin/users", Status: 200},
+		{Time: time.Unix(1700000001, 0).UTC(), Actor: "Admin", Action: "POST /api/admin/users/{login}/disable", Target: "User1", IP: "192.0.2.1", Status: 204},
+	}
+
+	log, err := NewFileAuditLog(path, 0, 0, zap.NewNop())
+	assert.NoError(t, err)
+	assert.NoError(t, log.Append(events[0]))
+	assert.NoError(t, log.Close())
+
+	// reopening appends instead of truncating
+	log, err = NewFileAuditLog(path, 0, 0, zap.NewNop())
+	assert.NoError(t, err)
+	assert.NoError(t, log.Append(events[1]))
+	assert.NoError(t, log.Close())
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var got []AuditEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		event := AuditEvent{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		got = append(got, event)
+	}
+	assert.Equal(t, events, got)
+}
+
+func TestFileAuditLog_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	line, err := json.Marshal(AuditEvent{Time: time.Unix(1700000000, 0).UTC(), Actor: "User1", Action: "login"})
+	assert.NoError(t, err)
+
+	// room for two events per file, two rotated files kept
+	log, err := NewFileAuditLog(path, int64(2*(len(line)+1)), 2, zap.NewNop())
+	assert.NoError(t, err)
+	defer log.Close()
+	rotations := time.Unix(1700000000, 0)
+	log.now = func() time.Time {
+		rotations = rotations.Add(time.Second)
+		return rotations
+	}
+
+	for i := 0; i < 7; i++ {
+		assert.NoError(t, log.Append(AuditEvent{Time: time.Unix(1700000000+int64(i), 0).UTC(), Actor: "User1", Action: "login"}))
+	}
+
+	rotated, err := filepath.Glob(path + ".*")
+	assert.NoError(t, err)
+	assert.Len(t, rotated, 2)
+
+	// the first two events went with the pruned file
+	events, err := log.Query(AuditFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 5)
+	assert.Equal(t, time.Unix(1700000006, 0).UTC(), events[0].Time)
+	assert.Equal(t, time.Unix(1700000002, 0).UTC(), events[4].Time)
+
+	events, err = log.Query(AuditFilter{From: time.Unix(1700000003, 0), To: time.Unix(1700000004, 0), Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, time.Unix(1700000004, 0).UTC(), events[0].Time)
+}
+
+func TestFileAuditLog_QueryStreams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := NewFileAuditLog(path, 0, 0, zap.NewNop())
+	assert.NoError(t, err)
+	defer log.Close()
+
+	// more than a block of lines, with an event still being written at the end
+	base := time.Unix(1700000000, 0).UTC()
+	for i := 0; i < 2000; i++ {
+		assert.NoError(t, log.Append(AuditEvent{Time: base.Add(time.Duration(i) * time.Second), Actor: "User1", Action: "login"}))
+	}
+	_, err = log.file.WriteString(`{"time":"2023-11-14T22:13:20Z","act`)
+	assert.NoError(t, err)
+
+	events, err := log.Query(AuditFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2000)
+	assert.Equal(t, base.Add(1999*time.Second), events[0].Time)
+	assert.Equal(t, base, events[1999].Time)
+
+	// the limit is met by the current file, the rotated one is not read
+	rotated := AuditEvent{Time: base.Add(-time.Second), Actor: "User1", Action: "login"}
+	line, err := json.Marshal(rotated)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path+".20231114T221320.000000000", append(line, '\n'), 0o600))
+	events, err = log.Query(AuditFilter{Limit: 3})
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, base.Add(1997*time.Second), events[2].Time)
+	events, err = log.Query(AuditFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2001)
+	assert.Equal(t, rotated, events[2000])
+}
+
+func TestFileAuditLog_BrokenLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	core, logs := observer.New(zapcore.WarnLevel)
+	events := []AuditEvent{
+		{Time: time.Unix(1700000000, 0).UTC(), Actor: "User1", Action: "login"},
+		{Time: time.Unix(1700000001, 0).UTC(), Actor: "User2", Action: "login"},
+		{Time: time.Unix(1700000002, 0).UTC(), Actor: "User3", Action: "login"},
+	}
+
+	// a crash left the second event half written
+	log, err := NewFileAuditLog(path, 0, 0, zap.New(core))
+	assert.NoError(t, err)
+	assert.NoError(t, log.Append(events[0]))
+	_, err = log.file.WriteString(`{"time":"2023-11-14T22:13:21Z","act`)
+	assert.NoError(t, err)
+	assert.NoError(t, log.Close())
+
+	// the events appended after the restart start on a line of their own
+	log, err = NewFileAuditLog(path, 0, 0, zap.New(core))
+	assert.NoError(t, err)
+	defer log.Close()
+	assert.NoError(t, log.Append(events[1]))
+	assert.NoError(t, log.Append(events[2]))
+
+	got, err := log.Query(AuditFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []AuditEvent{events[2], events[1], events[0]}, got)
+	assert.Equal(t, 1, logs.FilterMessage("skipped broken audit line").Len())
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	HistorySearch  = "search"
+	HistoryGeocode = "geocode"
+)
+
+// Address is a geocoding result kept with the user.
+type Address struct {
+	Address string  `json:"address"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+}
+
+// HistoryEntry is a successful lookup of the user.
+type HistoryEntry struct {
+	Time time.Time `json:"time"`
+	// Kind is HistorySearch or HistoryGeocode
+	Kind string `json:"kind"`
+	// Query is the searched address, or the coordinates as "lat,lon"
+	Query     string    `json:"query"`
+	Addresses []Address `json:"addresses,omitempty"`
+}
+
+// HistoryRepository keeps the search history of each user apart from the
+// user, only the last entries up to the limit of the store are kept.
+// Implementations must be safe for concurrent use.
+type HistoryRepository interface {
+	// Add records entry as the most recent lookup of login
+	Add(login string, entry HistoryEntry) error
+	// List returns up to limit entries of login, most recent first, skipping
+	// the first offset, and the number of entries kept. limit 0 means no
+	// limit.
+	List(login string, offset, limit int) ([]HistoryEntry, int, error)
+	Clear(login string) error
+}
+
+type MemoryHistoryRepository struct {
+	mu    sync.RWMutex
+	limit int
+	// history of each login, most recent first
+	history map[string][]HistoryEntry
+}
+
+func NewMemoryHistoryRepository(limit int) *MemoryHistoryRepository {
+	return &MemoryHistoryRepository{limit: limit, history: make(map[string][]HistoryEntry)}
+}
+
+func (m *MemoryHistoryRepository) Add(login string, entry HistoryEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := m.history[login]
+	n := len(history) + 1
+	if n > m.limit {
+		n = m.limit
+	}
+	if n == 0 {
+		return nil
+	}
+	// a new slice, pages returned by List may still reference the old one
+	m.history[login] = append(append(make([]HistoryEntry, 0, n), entry), history[:n-1]...)
+	return nil
+}
+
+func (m *MemoryHistoryRepository) List(login string, offset, limit int) ([]HistoryEntry, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries, total := historyPage(m.history[login], offset, limit)
+	return entries, total, nil
+}
+
+func (m *MemoryHistoryRepository) Clear(login string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.history, login)
+	return nil
+}
+
+// historyPage returns up to limit of history skipping the first offset, and
+// the length of history.
+func historyPage(history []HistoryEntry, offset, limit int) ([]HistoryEntry, int) {
+	entries := []HistoryEntry{}
+	if offset < len(history) {
+		end := len(history)
+		if limit > 0 && offset+limit < end {
+			end = offset + limit
+		}
+		entries = history[offset:end]
+	}
+	return entries, len(history)
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileHistoryRepository appends the lookups of each user to a file of its
+// own as JSON lines, oldest first, so recording a lookup writes one line.
+// Once a file holds twice the limit of entries it is rewritten with only the
+// last limit ones.
+type FileHistoryRepository struct {
+	mu    sync.Mutex
+	dir   string
+	limit int
+	// lines counts the entries in the file of each login read so far
+	lines map[string]int
+}
+
+func NewFileHistoryRepository(dir string, limit int) (*FileHistoryRepository, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error create dir %s: %v", dir, err)
+	}
+	return &FileHistoryRepository{dir: dir, limit: limit, lines: make(map[string]int)}, nil
+}
+
+func (f *FileHistoryRepository) Add(login string, entry HistoryEntry) error {
+	if f.limit <= 0 {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encode history entry: %v", err)
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := f.path(login)
+	lines, ok := f.lines[login]
+	if !ok {
+		entries, err := readHistoryFile(path)
+		if err != nil {
+			return err
+		}
+		lines = len(entries)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("error open %s: %v", path, err)
+	}
+	_, err = file.Write(line)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		delete(f.lines, login)
+		return fmt.Errorf("error write %s: %v", path, err)
+	}
+	f.lines[login] = lines + 1
+
+	if lines+1 < 2*f.limit {
+		return nil
+	}
+	return f.compact(login)
+}
+
+func (f *FileHistoryRepository) List(login string, offset, limit int) ([]HistoryEntry, int, error) {
+	f.mu.Lock()
+	entries, err := readHistoryFile(f.path(login))
+	f.mu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(entries) > f.limit {
+		entries = entries[len(entries)-f.limit:]
+	}
+	// the file is oldest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	entries, total := historyPage(entries, offset, limit)
+	return entries, total, nil
+}
+
+func (f *FileHistoryRepository) Clear(login string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := f.path(login)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error remove %s: %v", path, err)
+	}
+	f.lines[login] = 0
+	return nil
+}
+
+// compact rewrites the file of login with the last limit entries, the caller
+// must hold the lock.
+func (f *FileHistoryRepository) compact(login string) error {
+	path := f.path(login)
+	entries, err := readHistoryFile(path)
+	if err != nil {
+		return err
+	}
+	if len(entries) > f.limit {
+		entries = entries[len(entries)-f.limit:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("error encode history entry: %v", err)
+		}
+	}
+	if err := replaceFile(path, buf.Bytes()); err != nil {
+		return err
+	}
+	f.lines[login] = len(entries)
+	return nil
+}
+
+// path is the file of login, the login is hex encoded as it may hold any
+// character.
+func (f *FileHistoryRepository) path(login string) string {
+	return filepath.Join(f.dir, hex.EncodeToString([]byte(login))+".jsonl")
+}
+
+// readHistoryFile returns the entries of the file at path, oldest first. A
+// missing file holds no entries. Lines that cannot be decoded, such as one
+// torn by a crash while appending, are skipped.
+func readHistoryFile(path string) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := HistoryEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error read %s: %v", path, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryRepository(t *testing.T) {
+	fileRepo, err := NewFileHistoryRepository(t.TempDir(), 2)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		repo HistoryRepository
+	}{
+		{"memory", NewMemoryHistoryRepository(2)},
+		{"file", fileRepo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, query := range []string{"Москва", "Тверь", "Казань"} {
+				assert.NoError(t, tt.repo.Add("User1", HistoryEntry{Kind: HistorySearch, Query: query}))
+			}
+			assert.NoError(t, tt.repo.Add("../User2", HistoryEntry{Kind: HistoryGeocode, Query: "55.878,37.653"}))
+
+			entries, total, err := tt.repo.List("User1", 0, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Equal(t, []HistoryEntry{{Kind: HistorySearch, Query: "Казань"}, {Kind: HistorySearch, Query: "Тверь"}}, entries)
+
+			entries, total, err = tt.repo.List("User1", 1, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Equal(t, []HistoryEntry{{Kind: HistorySearch, Query: "Тверь"}}, entries)
+
+			entries, _, err = tt.repo.List("../User2", 0, 10)
+			assert.NoError(t, err)
+			assert.Equal(t, []HistoryEntry{{Kind: HistoryGeocode, Query: "55.878,37.653"}}, entries)
+
+			assert.NoError(t, tt.repo.Clear("User1"))
+			assert.NoError(t, tt.repo.Clear("User3"))
+			entries, total, err = tt.repo.List("User1", 0, 10)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, total)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestFileHistoryRepository_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := NewFileHistoryRepository(dir, 3)
+	assert.NoError(t, err)
+	for _, query := range []string{"Москва", "Тверь", "Казань", "Самара", "Омск", "Томск", "Пермь"} {
+		assert.NoError(t, repo.Add("User1", HistoryEntry{Kind: HistorySearch, Query: query}))
+	}
+	// the file was compacted on the sixth entry and appended to since
+	data, err := os.ReadFile(repo.path("User1"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, bytes.Count(data, []byte("\n")))
+
+	// a line torn by a crash is skipped
+	file, err := os.OpenFile(repo.path("User1"), os.O_WRONLY|os.O_APPEND, 0o600)
+	assert.NoError(t, err)
+	_, err = file.WriteString("{\"kind\":\"sea\n")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	reopened, err := NewFileHistoryRepository(dir, 3)
+	assert.NoError(t, err)
+	entries, total, err := reopened.List("User1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []HistoryEntry{{Kind: HistorySearch, Query: "Пермь"}, {Kind: HistorySearch, Query: "Томск"}, {Kind: HistorySearch, Query: "Омск"}}, entries)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPlaceExists   = errors.New("place already exists")
+	ErrPlaceNotFound = errors.New("place not found")
+)
+
+// Place is an address the user saved.
+type Place struct {
+	ID string `json:"id"`
+	Address
+	Label     string    `json:"label"`
+	Tags      []string  `json:"tags,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (p Place) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// withPlace returns u with place added, or replacing the place with the same
+// ID when replace is set. The places of u are not modified, they may still
+// be referenced.
+func (u User) withPlace(place Place, replace bool) (User, error) {
+	places := make([]Place, 0, len(u.Places)+1)
+	found := false
+	for _, p := range u.Places {
+		if p.ID == place.ID {
+			found = true
+			if replace {
+				p = place
+			}
+		}
+		places = append(places, p)
+	}
+	switch {
+	case replace && !found:
+		return User{}, ErrPlaceNotFound
+	case !replace && found:
+		return User{}, ErrPlaceExists
+	case !replace:
+		places = append(places, place)
+	}
+	u.Places = places
+	return u, nil
+}
+
+// withoutPlace returns u without the place id.
+func (u User) withoutPlace(id string) (User, error) {
+	places := make([]Place, 0, len(u.Places))
+	for _, p := range u.Places {
+		if p.ID != id {
+			places = append(places, p)
+		}
+	}
+	if len(places) == len(u.Places) {
+		return User{}, ErrPlaceNotFound
+	}
+	u.Places = places
+	return u, nil
+}
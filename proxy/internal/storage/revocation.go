@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore is a denylist of token IDs ("jti" claims). Entries only
+// need to live as long as the token itself, an expired token is rejected
+// anyway. Implementations must be safe for concurrent use.
+type RevocationStore interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
+
+type MemoryRevocationStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time), now: time.Now}
+}
+
+func (m *MemoryRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune()
+	m.revoked[jti] = expiresAt
+	return nil
+}
+
+func (m *MemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	expiresAt, ok := m.revoked[jti]
+	return ok && m.now().Before(expiresAt), nil
+}
+
+// prune drops entries of tokens that have already expired, the caller must
+// hold the write lock.
+func (m *MemoryRevocationStore) prune() {
+	now := m.now()
+	for jti, expiresAt := range m.revoked {
+		if !now.Before(expiresAt) {
+			delete(m.revoked, jti)
+		}
+	}
+}
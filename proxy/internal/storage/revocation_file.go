@@ -0,0 +1,39 @@
+package storage
+
+import "time"
+
+// FileRevocationStore keeps the denylist in memory and persists every change
+// to a JSON file, so revoked tokens stay revoked after a restart.
+type FileRevocationStore struct {
+	path string
+	MemoryRevocationStore
+}
+
+func NewFileRevocationStore(path string) (*FileRevocationStore, error) {
+	revoked := make(map[string]time.Time)
+	if err := readJSONFile(path, &revoked); err != nil {
+		return nil, err
+	}
+	if revoked == nil {
+		revoked = make(map[string]time.Time)
+	}
+	return &FileRevocationStore{path: path, MemoryRevocationStore: MemoryRevocationStore{revoked: revoked, now: time.Now}}, nil
+}
+
+func (f *FileRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old, existed := f.revoked[jti]
+	f.prune()
+	f.revoked[jti] = expiresAt
+	if err := writeJSONFile(f.path, f.revoked); err != nil {
+		if existed {
+			f.revoked[jti] = old
+		} else {
+			delete(f.revoked, jti)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationStore(t *testing.T) {
+	fileStore, err := NewFileRevocationStore(filepath.Join(t.TempDir(), "revoked.json"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		store RevocationStore
+	}{
+		{"memory", NewMemoryRevocationStore()},
+		{"file", fileStore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.store.Revoke("jti1", time.Now().Add(time.Hour)))
+			assert.NoError(t, tt.store.Revoke("jti2", time.Now().Add(-time.Second)))
+
+			revoked, err := tt.store.IsRevoked("jti1")
+			assert.NoError(t, err)
+			assert.True(t, revoked)
+
+			revoked, err = tt.store.IsRevoked("jti2")
+			assert.NoError(t, err)
+			assert.False(t, revoked)
+
+			revoked, err = tt.store.IsRevoked("jti3")
+			assert.NoError(t, err)
+			assert.False(t, revoked)
+		})
+	}
+}
+
+func TestMemoryRevocationStore_Expiry(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRevocationStore()
+	store.now = func() time.Time { return now }
+
+	assert.NoError(t, store.Revoke("jti1", now.Add(time.Minute)))
+	revoked, _ := store.IsRevoked("jti1")
+	assert.True(t, revoked)
+
+	now = now.Add(2 * time.Minute)
+	revoked, _ = store.IsRevoked("jti1")
+	assert.False(t, revoked)
+
+	assert.NoError(t, store.Revoke("jti2", now.Add(time.Minute)))
+	assert.Len(t, store.revoked, 1)
+}
+
+func TestFileRevocationStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "revoked.json")
+
+	store, err := NewFileRevocationStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Revoke("jti1", time.Now().Add(time.Hour)))
+	assert.NoError(t, store.Revoke("jti2", time.Now().Add(time.Hour)))
+
+	reopened, err := NewFileRevocationStore(path)
+	assert.NoError(t, err)
+	revoked, err := reopened.IsRevoked("jti1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, _ = reopened.IsRevoked("jti2")
+	assert.True(t, revoked)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// usageRetention is how many days of usage counts are kept.
+const usageRetention = 31
+
+// UsageRepository counts calls to paid providers per UTC day.
+// Implementations must be safe for concurrent use.
+type UsageRepository interface {
+	// Increment counts one more call of provider on day and returns the new
+	// count. It returns ErrQuotaExceeded without counting once limit calls
+	// were made, limit 0 means no limit.
+	Increment(provider string, day time.Time, limit int) (int, error)
+	Usage(provider string, day time.Time) (int, error)
+}
+
+type MemoryUsageRepository struct {
+	mu    sync.RWMutex
+	usage map[string]map[string]int
+}
+
+func NewMemoryUsageRepository() *MemoryUsageRepository {
+	return &MemoryUsageRepository{usage: make(map[string]map[string]int)}
+}
+
+func (m *MemoryUsageRepository) Increment(provider string, day time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.increment(provider, day, limit)
+}
+
+func (m *MemoryUsageRepository) Usage(provider string, day time.Time) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.usage[dayKey(day)][provider], nil
+}
+
+// increment does the work of Increment, the caller must hold the write lock.
+func (m *MemoryUsageRepository) increment(provider string, day time.Time, limit int) (int, error) {
+	key := dayKey(day)
+	calls := m.usage[key][provider]
+	if limit > 0 && calls >= limit {
+		return calls, ErrQuotaExceeded
+	}
+
+	if m.usage[key] == nil {
+		m.prune(day)
+		m.usage[key] = make(map[string]int)
+	}
+	m.usage[key][provider] = calls + 1
+	return calls + 1, nil
+}
+
+// prune drops the counts of days past the retention period, the caller must
+// hold the write lock.
+func (m *MemoryUsageRepository) prune(day time.Time) {
+	oldest := dayKey(day.AddDate(0, 0, -usageRetention))
+	for key := range m.usage {
+		// "2006-01-02" keys sort chronologically
+		if key < oldest {
+			delete(m.usage, key)
+		}
+	}
+}
+
+func dayKey(day time.Time) string {
+	return day.UTC().Format("2006-01-02")
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FileUsageRepository keeps the counts in memory and writes them to a JSON
+// file every flushInterval when they changed, so quotas are not reset by a
+// restart. Calls counted since the last flush are lost on a crash, Close
+// flushes them on shutdown. Without a flushInterval every call is written
+// right away.
+type FileUsageRepository struct {
+	path          string
+	flushInterval time.Duration
+	log           *zap.Logger
+	MemoryUsageRepository
+	// dirty is set when the counts changed since the last flush, guarded by mu
+	dirty bool
+
+	// flushMu keeps flushes in order, so an older snapshot never replaces a
+	// newer one
+	flushMu sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewFileUsageRepository(path string, flushInterval time.Duration, logger *zap.Logger) (*FileUsageRepository, error) {
+	usage := make(map[string]map[string]int)
+	if err := readJSONFile(path, &usage); err != nil {
+		return nil, err
+	}
+	if usage == nil {
+		usage = make(map[string]map[string]int)
+	}
+	f := &FileUsageRepository{
+		path:                  path,
+		flushInterval:         flushInterval,
+		log:                   logger,
+		MemoryUsageRepository: MemoryUsageRepository{usage: usage},
+		stop:                  make(chan struct{}),
+		done:                  make(chan struct{}),
+	}
+	go f.flushLoop()
+	return f, nil
+}
+
+func (f *FileUsageRepository) Increment(provider string, day time.Time, limit int) (int, error) {
+	f.mu.Lock()
+	calls, err := f.increment(provider, day, limit)
+	if err == nil {
+		f.dirty = true
+	}
+	f.mu.Unlock()
+
+	if err == nil && f.flushInterval <= 0 {
+		f.flush()
+	}
+	return calls, err
+}
+
+// Flush writes the counts to the file if they changed since the last flush.
+func (f *FileUsageRepository) Flush() error {
+	f.flushMu.Lock()
+	defer f.flushMu.Unlock()
+
+	f.mu.Lock()
+	if !f.dirty {
+		f.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(f.usage)
+	f.dirty = false
+	f.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encode %s: %v", f.path, err)
+	}
+
+	if err := replaceFile(f.path, data); err != nil {
+		// written with the next flush
+		f.mu.Lock()
+		f.dirty = true
+		f.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close stops flushing on the timer and flushes the counts a last time.
+func (f *FileUsageRepository) Close() error {
+	close(f.stop)
+	<-f.done
+	return f.Flush()
+}
+
+func (f *FileUsageRepository) flushLoop() {
+	defer close(f.done)
+	if f.flushInterval <= 0 {
+		<-f.stop
+		return
+	}
+
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.flush()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// flush logs a failed Flush, the counts stay dirty and are written with the
+// next one.
+func (f *FileUsageRepository) flush() {
+	if err := f.Flush(); err != nil {
+		f.log.Error("usage flush failed", zap.String("file", f.path), zap.Error(err))
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestUsageRepository(t *testing.T) {
+	fileRepo, err := NewFileUsageRepository(filepath.Join(t.TempDir(), "usage.json"), time.Minute, zap.NewNop())
+	assert.NoError(t, err)
+	defer fileRepo.Close()
+
+	tests := []struct {
+		name string
+		repo UsageRepository
+	}{
+		{"memory", NewMemoryUsageRepository()},
+		{"file", fileRepo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			today := time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC)
+			tomorrow := today.Add(time.Minute)
+
+			for i := 1; i <= 2; i++ {
+				calls, err := tt.repo.Increment("dadata", today, 2)
+				assert.NoError(t, err)
+				assert.Equal(t, i, calls)
+			}
+			calls, err := tt.repo.Increment("dadata", today, 2)
+			assert.ErrorIs(t, err, ErrQuotaExceeded)
+			assert.Equal(t, 2, calls)
+
+			// other providers and days are counted separately
+			calls, err = tt.repo.Increment("other", today, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, calls)
+			calls, err = tt.repo.Increment("dadata", tomorrow, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, calls)
+
+			// 0 means no limit
+			calls, err = tt.repo.Increment("dadata", today, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, calls)
+
+			calls, err = tt.repo.Usage("dadata", today)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, calls)
+			calls, _ = tt.repo.Usage("dadata", today.AddDate(0, 0, -1))
+			assert.Equal(t, 0, calls)
+		})
+	}
+}
+
+func TestMemoryUsageRepository_Prune(t *testing.T) {
+	repo := NewMemoryUsageRepository()
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	_, _ = repo.Increment("dadata", day, 0)
+	_, _ = repo.Increment("dadata", day.AddDate(0, 0, usageRetention), 0)
+	assert.Len(t, repo.usage, 2)
+	_, _ = repo.Increment("dadata", day.AddDate(0, 0, usageRetention+1), 0)
+	assert.Len(t, repo.usage, 2)
+}
+
+func TestFileUsageRepository_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "usage.json")
+	day := time.Now()
+
+	repo, err := NewFileUsageRepository(path, time.Minute, zap.NewNop())
+	assert.NoError(t, err)
+	_, _ = repo.Increment("dadata", day, 0)
+	_, _ = repo.Increment("dadata", day, 0)
+	// nothing is written before the next flush
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.NoError(t, repo.Close())
+
+	reopened, err := NewFileUsageRepository(path, time.Minute, zap.NewNop())
+	assert.NoError(t, err)
+	defer reopened.Close()
+	calls, err := reopened.Usage("dadata", day)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	_, err = reopened.Increment("dadata", day, 2)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestFileUsageRepository_FlushInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	day := time.Now()
+
+	repo, err := NewFileUsageRepository(path, 10*time.Millisecond, zap.NewNop())
+	assert.NoError(t, err)
+	defer repo.Close()
+	_, _ = repo.Increment("dadata", day, 0)
+
+	assert.Eventually(t, func() bool {
+		usage := make(map[string]map[string]int)
+		return readJSONFile(path, &usage) == nil && usage[dayKey(day)]["dadata"] == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFileUsageRepository_WriteThrough(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Now()
+	core, logs := observer.New(zapcore.ErrorLevel)
+
+	// without an interval every call is written right away
+	path := filepath.Join(dir, "usage.json")
+	repo, err := NewFileUsageRepository(path, 0, zap.New(core))
+	assert.NoError(t, err)
+	defer repo.Close()
+	_, _ = repo.Increment("dadata", day, 0)
+	usage := make(map[string]map[string]int)
+	assert.NoError(t, readJSONFile(path, &usage))
+	assert.Equal(t, 1, usage[dayKey(day)]["dadata"])
+	assert.Zero(t, logs.Len())
+
+	// a failed write is logged, the call is still counted
+	broken, err := NewFileUsageRepository(filepath.Join(dir, "file", "usage.json"), 0, zap.New(core))
+	assert.NoError(t, err)
+	defer broken.Close()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0o644))
+	calls, err := broken.Increment("dadata", day, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 1, logs.FilterMessage("usage flush failed").Len())
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleBatch is for service accounts running bulk jobs
+	RoleBatch = "batch"
+)
+
+type User struct {
+	Login    string   `json:"login"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles,omitempty"`
+	// TokenEpoch is embedded in issued tokens and replaced whenever the
+	// password changes, which invalidates every token issued before
+	TokenEpoch string `json:"token_epoch,omitempty"`
+	// Disabled users can neither log in nor use tokens or API keys issued
+	// to them
+	Disabled bool `json:"disabled,omitempty"`
+	// Places the user saved, oldest first
+	Places []Place `json:"places,omitempty"`
+}
+
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (u User) withPassword(password, epoch string) User {
+	u.Password = password
+	u.TokenEpoch = epoch
+	return u
+}
+
+func (u User) withRoles(roles []string, epoch string) User {
+	changed := len(roles) != len(u.Roles)
+	for _, role := range roles {
+		changed = changed || !u.HasRole(role)
+	}
+	if changed {
+		u.TokenEpoch = epoch
+	}
+	u.Roles = roles
+	return u
+}
+
+func (u User) withDisabled(disabled bool, epoch string) User {
+	if disabled && !u.Disabled {
+		u.TokenEpoch = epoch
+	}
+	u.Disabled = disabled
+	return u
+}
+
+// UserRepository stores registered users. Implementations must be safe for
+// concurrent use.
+type UserRepository interface {
+	Create(user User) error
+	Get(login string) (User, error)
+	// List returns every user ordered by login
+	List() ([]User, error)
+	Update(user User) error
+	// SetPassword replaces the password hash and the token epoch of login
+	SetPassword(login, password, epoch string) error
+	// SetRoles replaces the roles of login, a change of the roles also
+	// replaces the token epoch
+	SetRoles(login string, roles []string, epoch string) error
+	// SetDisabled disables or enables login, disabling also replaces the
+	// token epoch. Nothing changes when login already is in that state.
+	SetDisabled(login string, disabled bool, epoch string) error
+	Delete(login string) error
+	CreatePlace(login string, place Place) error
+	// UpdatePlace replaces the place with the ID of place
+	UpdatePlace(login string, place Place) error
+	DeletePlace(login, id string) error
+}
+
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[string]User)}
+}
+
+func (m *MemoryUserRepository) Create(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.Login]; ok {
+		return ErrUserExists
+	}
+	m.users[user.Login] = user
+	return nil
+}
+
+func (m *MemoryUserRepository) Get(login string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[login]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (m *MemoryUserRepository) List() ([]User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Login < users[j].Login })
+	return users, nil
+}
+
+func (m *MemoryUserRepository) Update(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.Login]; !ok {
+		return ErrUserNotFound
+	}
+	m.users[user.Login] = user
+	return nil
+}
+
+func (m *MemoryUserRepository) Delete(login string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[login]; !ok {
+		return ErrUserNotFound
+	}
+	delete(m.users, login)
+	return nil
+}
+
+func (m *MemoryUserRepository) SetPassword(login, password, epoch string) error {
+	return m.modify(login, func(user User) (User, error) {
+		return user.withPassword(password, epoch), nil
+	})
+}
+
+func (m *MemoryUserRepository) SetRoles(login string, roles []string, epoch string) error {
+	return m.modify(login, func(user User) (User, error) {
+		return user.withRoles(roles, epoch), nil
+	})
+}
+
+func (m *MemoryUserRepository) SetDisabled(login string, disabled bool, epoch string) error {
+	return m.modify(login, func(user User) (User, error) {
+		return user.withDisabled(disabled, epoch), nil
+	})
+}
+
+func (m *MemoryUserRepository) CreatePlace(login string, place Place) error {
+	return m.modify(login, func(user User) (User, error) {
+		return user.withPlace(place, false)
+	})
+}
+
+func (m *MemoryUserRepository) UpdatePlace(login string, place Place) error {
+	return m.modify(login, func(user User) (User, error) {
+		return user.withPlace(place, true)
+	})
+}
+
+func (m *MemoryUserRepository) DeletePlace(login, id string) error {
+	return m.modify(login, func(user User) (User, error) {
+		return user.withoutPlace(id)
+	})
+}
+
+// modify replaces the user with what fn makes of it while holding the lock,
+// so concurrent changes are not lost. The user is kept when fn fails.
+func (m *MemoryUserRepository) modify(login string, fn func(User) (User, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[login]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user, err := fn(user)
+	if err != nil {
+		return err
+	}
+	m.users[login] = user
+	return nil
+}
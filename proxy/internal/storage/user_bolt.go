@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var usersBucket = []byte("users")
+
+// BoltUserRepository keeps users in an embedded bbolt database, one record
+// per login, so a change only writes the user it touches.
+type BoltUserRepository struct {
+	db *bolt.DB
+}
+
+func NewBoltUserRepository(path string) (*BoltUserRepository, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error create dir %s: %v", dir, err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error open %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error init %s: %v", path, err)
+	}
+	return &BoltUserRepository{db: db}, nil
+}
+
+func (b *BoltUserRepository) Create(user User) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket.Get([]byte(user.Login)) != nil {
+			return ErrUserExists
+		}
+		return putUser(bucket, user)
+	})
+}
+
+func (b *BoltUserRepository) Get(login string) (User, error) {
+	var user User
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		user, err = getUser(tx.Bucket(usersBucket), login)
+		return err
+	})
+	return user, err
+}
+
+func (b *BoltUserRepository) List() ([]User, error) {
+	users := make([]User, 0)
+	// keys are kept sorted, so users come ordered by login
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			user := User{}
+			if err := json.Unmarshal(v, &user); err != nil {
+				return fmt.Errorf("error decode user %s: %v", k, err)
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (b *BoltUserRepository) Update(user User) error {
+	return b.modify(user.Login, func(User) (User, error) {
+		return user, nil
+	})
+}
+
+func (b *BoltUserRepository) Delete(login string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket.Get([]byte(login)) == nil {
+			return ErrUserNotFound
+		}
+		return bucket.Delete([]byte(login))
+	})
+}
+
+func (b *BoltUserRepository) SetPassword(login, password, epoch string) error {
+	return b.modify(login, func(user User) (User, error) {
+		return user.withPassword(password, epoch), nil
+	})
+}
+
+func (b *BoltUserRepository) SetRoles(login string, roles []string, epoch string) error {
+	return b.modify(login, func(user User) (User, error) {
+		return user.withRoles(roles, epoch), nil
+	})
+}
+
+func (b *BoltUserRepository) SetDisabled(login string, disabled bool, epoch string) error {
+	return b.modify(login, func(user User) (User, error) {
+		return user.withDisabled(disabled, epoch), nil
+	})
+}
+
+func (b *BoltUserRepository) CreatePlace(login string, place Place) error {
+	return b.modify(login, func(user User) (User, error) {
+		return user.withPlace(place, false)
+	})
+}
+
+func (b *BoltUserRepository) UpdatePlace(login string, place Place) error {
+	return b.modify(login, func(user User) (User, error) {
+		return user.withPlace(place, true)
+	})
+}
+
+func (b *BoltUserRepository) DeletePlace(login, id string) error {
+	return b.modify(login, func(user User) (User, error) {
+		return user.withoutPlace(id)
+	})
+}
+
+func (b *BoltUserRepository) Close() error {
+	return b.db.Close()
+}
+
+// modify replaces the user with what fn makes of it in one transaction.
+func (b *BoltUserRepository) modify(login string, fn func(User) (User, error)) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		user, err := getUser(bucket, login)
+		if err != nil {
+			return err
+		}
+		user, err = fn(user)
+		if err != nil {
+			return err
+		}
+		return putUser(bucket, user)
+	})
+}
+
+func getUser(bucket *bolt.Bucket, login string) (User, error) {
+	data := bucket.Get([]byte(login))
+	if data == nil {
+		return User{}, ErrUserNotFound
+	}
+	user := User{}
+	if err := json.Unmarshal(data, &user); err != nil {
+		return User{}, fmt.Errorf("error decode user %s: %v", login, err)
+	}
+	return user, nil
+}
+
+func putUser(bucket *bolt.Bucket, user User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("error encode user %s: %v", user.Login, err)
+	}
+	return bucket.Put([]byte(user.Login), data)
+}
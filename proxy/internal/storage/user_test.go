@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserRepository(t *testing.T) {
+	boltRepo, err := NewBoltUserRepository(filepath.Join(t.TempDir(), "users.db"))
+	assert.NoError(t, err)
+	defer boltRepo.Close()
+
+	tests := []struct {
+		name string
+		repo UserRepository
+	}{
+		{"memory", NewMemoryUserRepository()},
+		{"bolt", boltRepo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.repo.Create(User{Login: "User1", Password: "hash1"}))
+			assert.ErrorIs(t, tt.repo.Create(User{Login: "User1", Password: "hash2"}), ErrUserExists)
+
+			user, err := tt.repo.Get("User1")
+			assert.NoError(t, err)
+			assert.Equal(t, "hash1", user.Password)
+
+			_, err = tt.repo.Get("User2")
+			assert.ErrorIs(t, err, ErrUserNotFound)
+
+			assert.NoError(t, tt.repo.Update(User{Login: "User1", Password: "hash3"}))
+			assert.ErrorIs(t, tt.repo.Update(User{Login: "User2"}), ErrUserNotFound)
+			user, _ = tt.repo.Get("User1")
+			assert.Equal(t, "hash3", user.Password)
+
+			assert.NoError(t, tt.repo.Create(User{Login: "Admin"}))
+			users, err := tt.repo.List()
+			assert.NoError(t, err)
+			assert.Equal(t, []User{{Login: "Admin"}, {Login: "User1", Password: "hash3"}}, users)
+			assert.NoError(t, tt.repo.Delete("Admin"))
+
+			assert.NoError(t, tt.repo.SetRoles("User1", []string{RoleUser, RoleBatch}, "epoch0"))
+			user, _ = tt.repo.Get("User1")
+			assert.Equal(t, "epoch0", user.TokenEpoch)
+			// the same roles in another order are no change
+			assert.NoError(t, tt.repo.SetRoles("User1", []string{RoleBatch, RoleUser}, "epoch1"))
+			user, _ = tt.repo.Get("User1")
+			assert.Equal(t, "epoch0", user.TokenEpoch)
+			assert.NoError(t, tt.repo.SetRoles("User1", []string{RoleUser, RoleBatch}, "epoch1"))
+			assert.NoError(t, tt.repo.SetPassword("User1", "hash4", "epoch1"))
+			assert.NoError(t, tt.repo.SetDisabled("User1", true, "epoch2"))
+			// already disabled, the epoch stays
+			assert.NoError(t, tt.repo.SetDisabled("User1", true, "epoch3"))
+			user, _ = tt.repo.Get("User1")
+			assert.Equal(t, User{Login: "User1", Password: "hash4", Roles: []string{RoleUser, RoleBatch}, TokenEpoch: "epoch2", Disabled: true}, user)
+			assert.NoError(t, tt.repo.SetDisabled("User1", false, "epoch4"))
+			user, _ = tt.repo.Get("User1")
+			assert.Equal(t, "epoch2", user.TokenEpoch)
+			assert.False(t, user.Disabled)
+			assert.ErrorIs(t, tt.repo.SetRoles("User2", nil, "epoch"), ErrUserNotFound)
+			assert.ErrorIs(t, tt.repo.SetPassword("User2", "hash", "epoch"), ErrUserNotFound)
+			assert.ErrorIs(t, tt.repo.SetDisabled("User2", true, "epoch"), ErrUserNotFound)
+
+			assert.NoError(t, tt.repo.Delete("User1"))
+			assert.ErrorIs(t, tt.repo.Delete("User1"), ErrUserNotFound)
+		})
+	}
+}
+
+func TestUserRepository_Places(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	boltRepo, err := NewBoltUserRepository(path)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		repo UserRepository
+	}{
+		{"memory", NewMemoryUserRepository()},
+		{"bolt", boltRepo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := Place{ID: "p1", Address: Address{Address: "г Москва, ул Сухонская, д 11", Lat: 55.878, Lon: 37.653}, Label: "Home", Tags: []string{"family"}}
+			work := Place{ID: "p2", Address: Address{Address: "г Москва, Ленинский пр-кт, д 1", Lat: 55.725, Lon: 37.607}, Label: "Work"}
+
+			assert.NoError(t, tt.repo.Create(User{Login: "User1"}))
+			assert.NoError(t, tt.repo.CreatePlace("User1", home))
+			assert.NoError(t, tt.repo.CreatePlace("User1", work))
+			assert.ErrorIs(t, tt.repo.CreatePlace("User1", home), ErrPlaceExists)
+			assert.ErrorIs(t, tt.repo.CreatePlace("User2", home), ErrUserNotFound)
+
+			home.Notes = "code 42"
+			assert.NoError(t, tt.repo.UpdatePlace("User1", home))
+			assert.ErrorIs(t, tt.repo.UpdatePlace("User1", Place{ID: "p3"}), ErrPlaceNotFound)
+
+			user, err := tt.repo.Get("User1")
+			assert.NoError(t, err)
+			assert.Equal(t, []Place{home, work}, user.Places)
+
+			assert.NoError(t, tt.repo.DeletePlace("User1", "p2"))
+			assert.ErrorIs(t, tt.repo.DeletePlace("User1", "p2"), ErrPlaceNotFound)
+			user, _ = tt.repo.Get("User1")
+			assert.Equal(t, []Place{home}, user.Places)
+		})
+	}
+
+	assert.NoError(t, boltRepo.Close())
+	reopened, err := NewBoltUserRepository(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	user, err := reopened.Get("User1")
+	assert.NoError(t, err)
+	assert.Len(t, user.Places, 1)
+	assert.Equal(t, "code 42", user.Places[0].Notes)
+}
+
+func TestUser_HasRole(t *testing.T) {
+	user := User{Login: "User1", Roles: []string{RoleUser, RoleBatch}}
+	assert.True(t, user.HasRole(RoleBatch))
+	assert.False(t, user.HasRole(RoleAdmin))
+	assert.False(t, User{}.HasRole(RoleUser))
+}
+
+func TestBoltUserRepository_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "users.db")
+
+	repo, err := NewBoltUserRepository(path)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Create(User{Login: "User1", Password: "hash1"}))
+	assert.NoError(t, repo.Create(User{Login: "User2", Password: "hash2"}))
+	assert.NoError(t, repo.Delete("User2"))
+
+	assert.NoError(t, repo.Close())
+	reopened, err := NewBoltUserRepository(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	user, err := reopened.Get("User1")
+	assert.NoError(t, err)
+	assert.Equal(t, "hash1", user.Password)
+	_, err = reopened.Get("User2")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestMemoryUserRepository_Concurrent(t *testing.T) {
+	repo := NewMemoryUserRepository()
+
+	var wg sync.WaitGroup
+	created := make(chan struct{}, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if repo.Create(User{Login: fmt.Sprintf("User%d", i%10)}) == nil {
+				created <- struct{}{}
+			}
+			_, _ = repo.Get("User1")
+		}(i)
+	}
+	wg.Wait()
+	close(created)
+
+	assert.Len(t, created, 10)
+}
@@ -22,31 +22,71 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"test/proxy/internal/auth"
+	"test/proxy/internal/config"
 	"test/proxy/internal/controller"
+	"test/proxy/internal/ratelimit"
 	"test/proxy/internal/responder"
 	"test/proxy/internal/service"
+	"test/proxy/internal/storage"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/ptflp/godecoder"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/bcrypt"
 )
 
 //go:generate swagger generate spec -o ./docs/swagger.json --scan-models
 type Router struct {
-	r *chi.Mux
-	c controller.Controllerer
+	r         *chi.Mux
+	c         controller.Controllerer
+	tokenAuth *auth.JWTAuth
+	limits    config.RateLimit
+	// closers are the stores to close on shutdown, in the order they were
+	// opened
+	closers []io.Closer
 }
 
-func init() {
-	service.TokenAuth = jwtauth.New("HS256", []byte("salt_01"), nil)
+// Close closes the stores in reverse order, pending writes such as usage
+// counts are flushed. It returns the first error, every store is closed
+// anyway.
+func (router *Router) Close() error {
+	var first error
+	for i := len(router.closers) - 1; i >= 0; i-- {
+		if err := router.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// limit is the rate limit middleware of route. Routes without a limit of
+// their own get the default one, each route has its own budget.
+func (router *Router) limit(route string) func(http.Handler) http.Handler {
+	rate, ok := router.limits.Routes[route]
+	if !ok {
+		rate = router.limits.Default
+	}
+	if rate.PerSecond <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return router.c.RateLimit(ratelimit.NewLimiter(rate.PerSecond, rate.Burst))
 }
 
 func (router *Router) handleRoutes() {
@@ -78,12 +118,22 @@ func (router *Router) handleRoutes() {
 	//     in: body
 	//     schema:
 	//       $ref: "#/definitions/errorResponse"
+	//   "423":
+	//     description: account is locked after too many failed attempts, retry after the Retry-After header
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
+	//   "429":
+	//     description: too many requests or failed attempts, retry after the Retry-After header
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
 	//   "500":
 	//     description: internal server error
 	//     in: body
 	//     schema:
 	//       $ref: "#/definitions/errorResponse"
-	router.r.HandleFunc("/api/login", router.c.Login)
+	router.r.With(router.c.Audit("login"), router.limit("/api/login")).HandleFunc("/api/login", router.c.Login)
 
 	// swagger:operation POST /api/register user postRegisterUser
 	//
@@ -103,26 +153,104 @@ func (router *Router) handleRoutes() {
 	//     schema:
 	//       $ref: "#/definitions/tokenResponse"
 	//   "400":
-	//     description: bad request
+	//     description: malformed request, or a login or password failing the policy, errors lists every failed rule
 	//     in: body
 	//     schema:
-	//       $ref: "#/definitions/errorResponse"
+	//       $ref: "#/definitions/validationErrorResponse"
 	//   "409":
 	//     description: user already exists
 	//     in: body
 	//     schema:
 	//       $ref: "#/definitions/errorResponse"
+	//   "429":
+	//     description: too many requests, retry after the Retry-After header
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
 	//   "500":
 	//     description: internal server error
 	//     in: body
 	//     schema:
 	//       $ref: "#/definitions/errorResponse"
-	router.r.HandleFunc("/api/register", router.c.Register)
+	router.r.With(router.c.Audit("register"), router.limit("/api/register")).HandleFunc("/api/register", router.c.Register)
+
+	// swagger:operation POST /api/token/refresh token postRefreshToken
+	//
+	// Exchange a refresh token for a new token pair
+	//
+	// ---
+	// parameters:
+	//   - name: refreshRequest
+	//     in: body
+	//     required: true
+	//     schema:
+	//       $ref: "#/definitions/refreshRequest"
+	// responses:
+	//   "200":
+	//     description: new access and refresh tokens
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/tokenResponse"
+	//   "400":
+	//     description: bad request
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
+	//   "401":
+	//     description: refresh token is invalid or expired
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
+	//   "429":
+	//     description: too many requests, retry after the Retry-After header
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
+	//   "500":
+	//     description: internal server error
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
+	router.r.With(router.c.Audit("token.refresh"), router.limit("/api/token/refresh")).HandleFunc("/api/token/refresh", router.c.Refresh)
+
+	// swagger:operation GET /.well-known/jwks.json token getJWKS
+	//
+	// Public keys for verifying issued tokens
+	//
+	// ---
+	// responses:
+	//   "200":
+	//     description: JSON Web Key Set, empty for HMAC algorithms
+	//   "405":
+	//     description: method not allowed
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
+	router.r.HandleFunc("/.well-known/jwks.json", router.c.JWKS)
+
+	// swagger:operation GET /api/health health getHealth
+	//
+	// Availability of the geocoding providers and the state of their circuit breakers
+	//
+	// ---
+	// responses:
+	//   "200":
+	//     description: status is "degraded" while the breaker of a provider is not closed
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/healthResponse"
+	//   "405":
+	//     description: method not allowed
+	//     in: body
+	//     schema:
+	//       $ref: "#/definitions/errorResponse"
+	router.r.HandleFunc("/api/health", router.c.Health)
 
 	router.r.Group(func(r chi.Router) {
-		r.Use(jwtauth.Verifier(service.TokenAuth))
+		r.Use(auth.Verifier(router.tokenAuth))
+		r.Use(router.c.APIKeyVerifier)
 
-		r.Use(router.c.Authenticator(service.TokenAuth))
+		r.Use(router.c.Authenticator(router.tokenAuth))
 
 		// swagger:operation POST /api/address/search search postSearch
 		//
@@ -138,9 +266,14 @@ func (router *Router) handleRoutes() {
 		//   - name: Authorization
 		//     in: header
 		//     type: string
-		//     required: true
+		//     required: false
 		//     description: Bearer token for user authentication
 		//     example: Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiIxMjM0NTY3ODkwIiwibmFtZSI6IkpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyfQ
+		//   - name: X-API-Key
+		//     in: header
+		//     type: string
+		//     required: false
+		//     description: API key, an alternative to the Authorization header
 		// responses:
 		//   "200":
 		//     description: search results
@@ -152,17 +285,37 @@ func (router *Router) handleRoutes() {
 		//     in: body
 		//     schema:
 		//       $ref: "#/definitions/errorResponse"
+		//   "401":
+		//     description: token has been revoked or api key is invalid
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
 		//   "403":
 		//     description: forbidden
 		//     in: body
 		//     schema:
 		//       $ref: "#/definitions/errorResponse"
+		//   "429":
+		//     description: too many requests or the geocoding provider quota is exhausted, retry after the Retry-After header
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "503":
+		//     description: geocoding provider is unavailable, retry after the Retry-After header
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "504":
+		//     description: geocoding provider did not answer in time
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
 		//   "500":
 		//     description: internal server error
 		//     in: body
 		//     schema:
 		//       $ref: "#/definitions/errorResponse"
-		r.HandleFunc("/api/address/search", router.c.GeoSearch)
+		r.With(router.c.Audit("address.search"), router.limit("/api/address/search"), router.c.RequireScope(service.ScopeAddressSearch)).HandleFunc("/api/address/search", router.c.GeoSearch)
 
 		// swagger:operation POST /api/address/geocode geoCode postGeo
 		//
@@ -178,9 +331,14 @@ func (router *Router) handleRoutes() {
 		//   - name: Authorization
 		//     in: header
 		//     type: string
-		//     required: true
+		//     required: false
 		//     description: Bearer token for user authentication
 		//     example: Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiIxMjM0NTY3ODkwIiwibmFtZSI6IkpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyfQ
+		//   - name: X-API-Key
+		//     in: header
+		//     type: string
+		//     required: false
+		//     description: API key, an alternative to the Authorization header
 		// responses:
 		//   "200":
 		//     description: geoCode results
@@ -192,28 +350,1308 @@ func (router *Router) handleRoutes() {
 		//     in: body
 		//     schema:
 		//       $ref: "#/definitions/errorResponse"
+		//   "401":
+		//     description: token has been revoked or api key is invalid
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
 		//   "403":
 		//     description: forbidden
 		//     in: body
 		//     schema:
 		//       $ref: "#/definitions/errorResponse"
+		//   "429":
+		//     description: too many requests or the geocoding provider quota is exhausted, retry after the Retry-After header
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "503":
+		//     description: geocoding provider is unavailable, retry after the Retry-After header
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "504":
+		//     description: geocoding provider did not answer in time
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "500":
+		//     description: internal server error
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		r.With(router.c.Audit("address.geocode"), router.limit("/api/address/geocode"), router.c.RequireScope(service.ScopeAddressGeocode)).HandleFunc("/api/address/geocode", router.c.GeoCode)
+
+		r.Group(func(r chi.Router) {
+			r.Use(router.c.RequireRole(storage.RoleBatch))
+
+			// swagger:operation POST /api/address/search/batch search postSearchBatch
+			//
+			// Search for addresses by many query strings at once
+			//
+			// ---
+			// parameters:
+			//   - name: queries
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/batchSearchRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: false
+			//     description: Bearer token of a user with the batch role
+			//   - name: X-API-Key
+			//     in: header
+			//     type: string
+			//     required: false
+			//     description: API key, an alternative to the Authorization header
+			// responses:
+			//   "200":
+			//     description: one result per query in the order of the request, failed queries carry their own status and error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/batchResponse"
+			//   "400":
+			//     description: bad request, an empty batch or one over the maximum size
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "401":
+			//     description: token has been revoked or api key is invalid
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: forbidden, the batch role is required
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.c.Audit("address.search.batch"), router.limit("/api/address/search/batch"), router.c.RequireScope(service.ScopeAddressSearch)).HandleFunc("/api/address/search/batch", router.c.GeoSearchBatch)
+
+			// swagger:operation POST /api/address/geocode/batch geoCode postGeoBatch
+			//
+			// Search for addresses by many pairs of longitude and latitude at once
+			//
+			// ---
+			// parameters:
+			//   - name: points
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/batchGeocodeRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: false
+			//     description: Bearer token of a user with the batch role
+			//   - name: X-API-Key
+			//     in: header
+			//     type: string
+			//     required: false
+			//     description: API key, an alternative to the Authorization header
+			// responses:
+			//   "200":
+			//     description: one result per point in the order of the request, failed points carry their own status and error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/batchResponse"
+			//   "400":
+			//     description: bad request, an empty batch or one over the maximum size
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "401":
+			//     description: token has been revoked or api key is invalid
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: forbidden, the batch role is required
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.c.Audit("address.geocode.batch"), router.limit("/api/address/geocode/batch"), router.c.RequireScope(service.ScopeAddressGeocode)).HandleFunc("/api/address/geocode/batch", router.c.GeoCodeBatch)
+		})
+
+		// swagger:operation POST /api/logout token postLogout
+		//
+		// Revoke the access token of the current session and, optionally, its refresh token
+		//
+		// ---
+		// parameters:
+		//   - name: logoutRequest
+		//     in: body
+		//     required: false
+		//     schema:
+		//       $ref: "#/definitions/logoutRequest"
+		//   - name: Authorization
+		//     in: header
+		//     type: string
+		//     required: true
+		//     description: Bearer token for user authentication
+		// responses:
+		//   "204":
+		//     description: tokens revoked
+		//   "400":
+		//     description: bad request or refresh token of another user
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "401":
+		//     description: token has been revoked
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "403":
+		//     description: forbidden, API keys cannot log out, delete the key instead
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
+		//   "429":
+		//     description: too many requests, retry after the Retry-After header
+		//     in: body
+		//     schema:
+		//       $ref: "#/definitions/errorResponse"
 		//   "500":
 		//     description: internal server error
 		//     in: body
 		//     schema:
 		//       $ref: "#/definitions/errorResponse"
-		r.HandleFunc("/api/address/geocode", router.c.GeoCode)
+		r.With(router.c.Audit("logout"), router.limit("/api/logout")).HandleFunc("/api/logout", router.c.Logout)
+
+		r.Group(func(r chi.Router) {
+			r.Use(router.c.RequireScope(service.ScopeAccount))
+
+			// swagger:operation POST /api/user/password user postChangePassword
+			//
+			// Change the password of the current user, every token issued before is revoked
+			//
+			// ---
+			// parameters:
+			//   - name: changePasswordRequest
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/changePasswordRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: password changed, new access and refresh tokens
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/tokenResponse"
+			//   "400":
+			//     description: malformed request, or a new password failing the policy, errors lists every failed rule
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/validationErrorResponse"
+			//   "401":
+			//     description: token has been revoked
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: forbidden or wrong current password
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "423":
+			//     description: account is locked after too many failed attempts, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests or failed attempts, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.c.Audit("password.change"), router.limit("/api/user/password")).HandleFunc("/api/user/password", router.c.ChangePassword)
+
+			// swagger:operation DELETE /api/user user deleteUser
+			//
+			// Delete the current user and their API keys, every token of the user is revoked
+			//
+			// ---
+			// parameters:
+			//   - name: deleteAccountRequest
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/deleteAccountRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "204":
+			//     description: account deleted
+			//   "400":
+			//     description: bad request
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "401":
+			//     description: token has been revoked
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: forbidden or wrong password
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "423":
+			//     description: account is locked after too many failed attempts, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests or failed attempts, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.c.Audit("account.delete"), router.limit("/api/user")).HandleFunc("/api/user", router.c.DeleteAccount)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(router.c.RequireScope(service.ScopeHistory))
+
+			// swagger:operation GET /api/user/history user getHistory
+			//
+			// List the recent searches and geocoding lookups of the current user
+			//
+			// ---
+			// parameters:
+			//   - name: offset
+			//     in: query
+			//     type: integer
+			//     required: false
+			//     description: number of lookups to skip, 0 by default
+			//   - name: limit
+			//     in: query
+			//     type: integer
+			//     required: false
+			//     description: page size from 1 to 100, 20 by default
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: lookups, most recent first
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/historyResponse"
+			//   "400":
+			//     description: invalid offset or limit
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+
+			// swagger:operation DELETE /api/user/history user deleteHistory
+			//
+			// Clear the search history of the current user
+			//
+			// ---
+			// parameters:
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "204":
+			//     description: history cleared
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/user/history")).HandleFunc("/api/user/history", router.c.History)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(router.c.RequireScope(service.ScopePlaces))
+
+			// swagger:operation GET /api/user/places places getPlaces
+			//
+			// List the saved places of the current user, oldest first
+			//
+			// ---
+			// parameters:
+			//   - name: tag
+			//     in: query
+			//     type: string
+			//     required: false
+			//     description: only places with this tag
+			//   - name: q
+			//     in: query
+			//     type: string
+			//     required: false
+			//     description: case-insensitive part of the label, address or notes
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: saved places
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/placesResponse"
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+
+			// swagger:operation POST /api/user/places places postPlace
+			//
+			// Save an address for the current user
+			//
+			// ---
+			// parameters:
+			//   - name: placeRequest
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/placeRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: the saved place
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/placeResponse"
+			//   "400":
+			//     description: malformed request, or a place failing validation, errors lists every failed rule
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/validationErrorResponse"
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/user/places")).HandleFunc("/api/user/places", router.c.Places)
+
+			// swagger:operation GET /api/user/places/nearby places getNearbyPlaces
+			//
+			// Find saved places of the current user around a point, nearest first
+			//
+			// ---
+			// parameters:
+			//   - name: lat
+			//     in: query
+			//     type: number
+			//     required: true
+			//   - name: lng
+			//     in: query
+			//     type: number
+			//     required: true
+			//   - name: radius
+			//     in: query
+			//     type: integer
+			//     required: false
+			//     description: search radius in meters from 1 to 50000, 1000 by default
+			//   - name: limit
+			//     in: query
+			//     type: integer
+			//     required: false
+			//     description: number of places from 1 to 100, 20 by default
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: places in range with their distance
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/placesResponse"
+			//   "400":
+			//     description: invalid point, radius or limit
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/user/places/nearby")).HandleFunc("/api/user/places/nearby", router.c.NearbyPlaces)
+
+			// swagger:operation GET /api/user/places/{id} places getPlace
+			//
+			// Show a saved place
+			//
+			// ---
+			// parameters:
+			//   - name: id
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: the place
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/placeResponse"
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: place not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+
+			// swagger:operation PUT /api/user/places/{id} places putPlace
+			//
+			// Replace the address, label, tags and notes of a saved place
+			//
+			// ---
+			// parameters:
+			//   - name: id
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: placeRequest
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/placeRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: the updated place
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/placeResponse"
+			//   "400":
+			//     description: malformed request, or a place failing validation, errors lists every failed rule
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/validationErrorResponse"
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: place not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+
+			// swagger:operation DELETE /api/user/places/{id} places deletePlace
+			//
+			// Delete a saved place
+			//
+			// ---
+			// parameters:
+			//   - name: id
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "204":
+			//     description: place deleted
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: place not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/user/places/{id}")).HandleFunc("/api/user/places/{id}", router.c.Place)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(router.c.RequireScope(service.ScopeKeys))
+
+			// swagger:operation GET /api/keys apiKeys getAPIKeys
+			//
+			// List API keys of the current user
+			//
+			// ---
+			// parameters:
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: API keys without the secret part
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/apiKeysResponse"
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+
+			// swagger:operation POST /api/keys apiKeys postAPIKey
+			//
+			// Issue a new API key, the key is returned only once
+			//
+			// ---
+			// parameters:
+			//   - name: apiKeyRequest
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/apiKeyRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "200":
+			//     description: issued API key
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/apiKeyResponse"
+			//   "400":
+			//     description: bad request or unknown scope
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: forbidden, or a key granting scopes it does not have
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/keys")).HandleFunc("/api/keys", router.c.APIKeys)
+
+			// swagger:operation DELETE /api/keys/{id} apiKeys deleteAPIKey
+			//
+			// Revoke an API key of the current user
+			//
+			// ---
+			// parameters:
+			//   - name: id
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token for user authentication
+			// responses:
+			//   "204":
+			//     description: key revoked
+			//   "403":
+			//     description: forbidden
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: key not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/keys/{id}")).HandleFunc("/api/keys/{id}", router.c.DeleteAPIKey)
+		})
+
+		r.Group(func(r chi.Router) {
+			// denied attempts are recorded too
+			r.Use(router.c.Audit(""))
+			r.Use(router.c.RequireRole(storage.RoleAdmin))
+			r.Use(router.c.RequireScope(service.ScopeAdmin))
+
+			// swagger:operation POST /api/admin/tokens/revoke admin postRevokeToken
+			//
+			// Revoke any token issued by this service
+			//
+			// ---
+			// parameters:
+			//   - name: revokeRequest
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/revokeRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "204":
+			//     description: token revoked
+			//   "400":
+			//     description: bad request or token not issued by this service
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "401":
+			//     description: token has been revoked
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "500":
+			//     description: internal server error
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/tokens/revoke")).HandleFunc("/api/admin/tokens/revoke", router.c.RevokeToken)
+
+			// swagger:operation GET /api/admin/cache admin getCacheStats
+			//
+			// Geocoding cache counters
+			//
+			// ---
+			// parameters:
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "200":
+			//     description: cache counters since startup
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/cacheStatsResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+
+			// swagger:operation DELETE /api/admin/cache admin deleteCache
+			//
+			// Drop every cached geocoding response
+			//
+			// ---
+			// parameters:
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "204":
+			//     description: cache flushed
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/cache")).HandleFunc("/api/admin/cache", router.c.Cache)
+
+			// swagger:operation GET /api/admin/coalescing admin getCoalescingStats
+			//
+			// Counters of provider calls shared between identical concurrent lookups
+			//
+			// ---
+			// parameters:
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "200":
+			//     description: coalescing counters since startup
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/coalescingStatsResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "405":
+			//     description: method not allowed
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/coalescing")).HandleFunc("/api/admin/coalescing", router.c.Coalescing)
+
+			// swagger:operation POST /api/admin/users/{login}/unlock admin postUnlockUser
+			//
+			// Lift the lockout of a login after failed login attempts
+			//
+			// ---
+			// parameters:
+			//   - name: login
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "204":
+			//     description: login unlocked, or it was not locked
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/users/{login}/unlock")).HandleFunc("/api/admin/users/{login}/unlock", router.c.UnlockUser)
+
+			// swagger:operation GET /api/admin/users admin getUsers
+			//
+			// List users page by page, optionally only those whose login contains a search string
+			//
+			// ---
+			// parameters:
+			//   - name: login
+			//     in: query
+			//     type: string
+			//     required: false
+			//     description: case-insensitive part of the login
+			//   - name: offset
+			//     in: query
+			//     type: integer
+			//     required: false
+			//     description: number of users to skip, 0 by default
+			//   - name: limit
+			//     in: query
+			//     type: integer
+			//     required: false
+			//     description: page size from 1 to 100, 20 by default
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "200":
+			//     description: users ordered by login
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/usersResponse"
+			//   "400":
+			//     description: invalid offset or limit
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/users")).HandleFunc("/api/admin/users", router.c.AdminUsers)
+
+			// swagger:operation GET /api/admin/users/{login} admin getUser
+			//
+			// Show a user
+			//
+			// ---
+			// parameters:
+			//   - name: login
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "200":
+			//     description: the user
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/userResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+
+			// swagger:operation DELETE /api/admin/users/{login} admin deleteUserByAdmin
+			//
+			// Delete a user and their API keys, every token of the user is revoked
+			//
+			// ---
+			// parameters:
+			//   - name: login
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "204":
+			//     description: user deleted
+			//   "400":
+			//     description: admins cannot delete themselves
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/users/{login}")).HandleFunc("/api/admin/users/{login}", router.c.AdminUser)
+
+			// swagger:operation POST /api/admin/users/{login}/disable admin postDisableUser
+			//
+			// Disable a user, their tokens and API keys stop working until the user is enabled again
+			//
+			// ---
+			// parameters:
+			//   - name: login
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "204":
+			//     description: user disabled, or it already was
+			//   "400":
+			//     description: admins cannot disable themselves
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/users/{login}/disable")).HandleFunc("/api/admin/users/{login}/disable", router.c.DisableUser)
+
+			// swagger:operation POST /api/admin/users/{login}/enable admin postEnableUser
+			//
+			// Enable a disabled user, tokens issued before the user was disabled stay revoked
+			//
+			// ---
+			// parameters:
+			//   - name: login
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "204":
+			//     description: user enabled, or it was not disabled
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/users/{login}/enable")).HandleFunc("/api/admin/users/{login}/enable", router.c.EnableUser)
+
+			// swagger:operation PUT /api/admin/users/{login}/roles admin putUserRoles
+			//
+			// Replace the roles of a user, a change revokes the tokens issued to the user before
+			//
+			// ---
+			// parameters:
+			//   - name: login
+			//     in: path
+			//     type: string
+			//     required: true
+			//   - name: rolesRequest
+			//     in: body
+			//     required: true
+			//     schema:
+			//       $ref: "#/definitions/rolesRequest"
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "204":
+			//     description: roles replaced
+			//   "400":
+			//     description: bad request, unknown role, or an admin removing their own admin role
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "404":
+			//     description: user not found
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/users/{login}/roles")).HandleFunc("/api/admin/users/{login}/roles", router.c.UserRoles)
+
+			// swagger:operation GET /api/admin/audit admin getAuditEvents
+			//
+			// Query the audit log of authentication, geocoding and admin requests
+			//
+			// ---
+			// parameters:
+			//   - name: from
+			//     in: query
+			//     type: string
+			//     format: date-time
+			//     required: false
+			//     description: earliest event time, RFC 3339
+			//   - name: to
+			//     in: query
+			//     type: string
+			//     format: date-time
+			//     required: false
+			//     description: latest event time, RFC 3339
+			//   - name: user
+			//     in: query
+			//     type: string
+			//     required: false
+			//     description: login of the caller
+			//   - name: limit
+			//     in: query
+			//     type: integer
+			//     required: false
+			//     description: number of events from 1 to 1000, 100 by default
+			//   - name: Authorization
+			//     in: header
+			//     type: string
+			//     required: true
+			//     description: Bearer token of an admin
+			// responses:
+			//   "200":
+			//     description: matching events, most recent first
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/auditEventsResponse"
+			//   "400":
+			//     description: invalid time range or limit
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "403":
+			//     description: caller is not an admin
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			//   "429":
+			//     description: too many requests, retry after the Retry-After header
+			//     in: body
+			//     schema:
+			//       $ref: "#/definitions/errorResponse"
+			r.With(router.limit("/api/admin/audit")).HandleFunc("/api/admin/audit", router.c.AuditEvents)
+		})
 	})
 }
 
+// shutdownTimeout bounds waiting for the requests in flight on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	host := "http://hugo"
 	port := ":1313"
-	r := getProxyRouter(host, port)
-	http.ListenAndServe(":8080", r.r)
+	r, err := getProxyRouter(host, port, config.NewConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":8080", Handler: r.r}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.Println(err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println("error shutdown:", err)
+		}
+		cancel()
+	}
+	// only once no request uses them any more
+	if err := r.Close(); err != nil {
+		log.Println("error close stores:", err)
+	}
 }
 
-func getProxyRouter(host, port string) *Router {
+func getProxyRouter(host, port string, conf *config.Config) (router *Router, err error) {
 	logger := zap.New(zapcore.NewCore(
 		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
 		zapcore.Lock(os.Stdout),
@@ -221,16 +1659,231 @@ func getProxyRouter(host, port string) *Router {
 	))
 	decoder := godecoder.NewDecoder()
 	respond := responder.NewResponder(decoder, logger)
-	serv := service.NewGeoService(decoder)
+
+	// the stores are closed by Router.Close, or right away when the router
+	// cannot be built
+	var closers []io.Closer
+	keep := func(store interface{}) {
+		if closer, ok := store.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
+	}
+	defer func() {
+		if err != nil {
+			(&Router{closers: closers}).Close()
+		}
+	}()
+
+	usage, err := newUsageRepository(conf, logger)
+	if err != nil {
+		return nil, err
+	}
+	keep(usage)
+	provider, err := newGeocodingProvider(conf, decoder, logger, usage)
+	if err != nil {
+		return nil, err
+	}
+	users, err := newUserRepository(conf)
+	if err != nil {
+		return nil, err
+	}
+	keep(users)
+	revoked, err := newRevocationStore(conf)
+	if err != nil {
+		return nil, err
+	}
+	keep(revoked)
+	apiKeys, err := newAPIKeyRepository(conf)
+	if err != nil {
+		return nil, err
+	}
+	keep(apiKeys)
+	auditLog, err := newAuditLog(conf, logger)
+	if err != nil {
+		return nil, err
+	}
+	keep(auditLog)
+	jwtConf, err := jwtConfig(conf, logger)
+	if err != nil {
+		return nil, err
+	}
+	tokenAuth, err := auth.New(jwtConf, jwt.WithRequiredClaim(jwt.ExpirationKey), jwt.WithRequiredClaim(jwt.SubjectKey), jwt.WithRequiredClaim(jwt.JwtIDKey))
+	if err != nil {
+		return nil, err
+	}
+	passwords, err := passwordPolicy(conf.Password)
+	if err != nil {
+		return nil, err
+	}
+	serv := service.NewGeoService(provider, users, revoked, apiKeys, tokenAuth, passwords, conf.Admins...)
+	// lookups missing the cache are coalesced before reaching the provider
+	if conf.Coalesce {
+		serv = service.NewCoalescingGeoService(serv)
+	}
+	if conf.Cache.Size > 0 {
+		serv = service.NewCachedGeoService(serv, conf.Cache)
+	}
+	if conf.Lockout.MaxFailures > 0 {
+		serv = service.NewLockoutGeoService(serv, conf.Lockout, logger)
+	}
+	if conf.HistoryLimit > 0 {
+		history, err := newHistoryRepository(conf)
+		if err != nil {
+			return nil, err
+		}
+		keep(history)
+		serv = service.NewHistoryGeoService(serv, history, logger)
+	}
+	serv = service.NewAuditGeoService(serv, auditLog, logger)
+	// outermost, so every item of a batch goes through the decorators above
+	serv = service.NewBatchGeoService(serv, conf.Batch)
 	contrl := controller.NewController(respond, decoder, serv)
 
-	router := &Router{r: chi.NewRouter(), c: contrl}
+	router = &Router{r: chi.NewRouter(), c: contrl, tokenAuth: tokenAuth, limits: conf.RateLimit, closers: closers}
 
 	router.r.Use(NewReverseProxy(host, port, contrl).ReverseProxy)
 
 	router.handleRoutes()
 
-	return router
+	return router, nil
+}
+
+// devJWTSecret signs tokens in development and test when neither JWT_SECRET
+// nor JWT_KEYS is set, so a development instance starts without any setup.
+const devJWTSecret = "salt_01"
+
+// jwtConfig refuses to start without signing keys unless APP_ENV is
+// development or test, those fall back to devJWTSecret.
+func jwtConfig(conf *config.Config, logger *zap.Logger) (config.JWT, error) {
+	jwtConf := conf.JWT
+	if jwtConf.Secret != "" || len(jwtConf.Keys) > 0 {
+		return jwtConf, nil
+	}
+	if !conf.IsDev() {
+		return jwtConf, fmt.Errorf("JWT_SECRET or JWT_KEYS must be set in %q, the development secret is only used when APP_ENV is development or test", conf.Env)
+	}
+	logger.Warn("JWT_SECRET is not set, signing tokens with the development secret")
+	jwtConf.Secret = devJWTSecret
+	return jwtConf, nil
+}
+
+// passwordPolicy refuses a bcrypt cost that bcrypt would reject or silently
+// replace, so it is caught on start and not on the first registration.
+func passwordPolicy(conf config.Password) (*service.PasswordPolicy, error) {
+	if conf.BcryptCost < bcrypt.MinCost || conf.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, conf.BcryptCost)
+	}
+	return service.NewPasswordPolicy(conf), nil
+}
+
+// newGeocodingProvider chains the configured providers, each one is asked
+// when the previous fails or finds nothing.
+func newGeocodingProvider(conf *config.Config, decoder godecoder.Decoder, logger *zap.Logger, usage storage.UsageRepository) (service.GeocodingProvider, error) {
+	if len(conf.Providers) == 0 {
+		return nil, errors.New("no geocoding providers configured")
+	}
+
+	providers := make([]service.NamedProvider, 0, len(conf.Providers))
+	for _, name := range conf.Providers {
+		provider, err := newNamedProvider(name, conf, decoder, logger, usage)
+		if err != nil {
+			return nil, err
+		}
+		// a provider with an open breaker is skipped without waiting for it
+		if conf.Breaker.FailureThreshold > 0 {
+			provider = service.NewCircuitBreakerProvider(provider, conf.Breaker)
+		}
+		providers = append(providers, service.NamedProvider{Name: name, GeocodingProvider: provider})
+	}
+	return service.NewFailoverProvider(providers...), nil
+}
+
+func newNamedProvider(name string, conf *config.Config, decoder godecoder.Decoder, logger *zap.Logger, usage storage.UsageRepository) (service.GeocodingProvider, error) {
+	switch name {
+	case "dadata":
+		if conf.DaData.APIKey == "" || conf.DaData.SecretKey == "" {
+			if !conf.IsDev() {
+				return nil, fmt.Errorf("DADATA_API_KEY and DADATA_SECRET_KEY must be set in %q", conf.Env)
+			}
+			logger.Warn("DADATA_API_KEY or DADATA_SECRET_KEY is not set, dadata lookups will fail")
+		}
+		// every retry is a paid call, so it is limited too
+		provider := service.NewLimitedProvider(service.NewDaData(decoder, conf.DaData), name, conf.DaData.Limit, usage)
+		if conf.Retry.MaxAttempts > 1 {
+			provider = service.NewRetryingProvider(provider, conf.Retry, logger)
+		}
+		return provider, nil
+	case "gazetteer":
+		return service.LoadGazetteer(decoder, conf.Gazetteer)
+	default:
+		return nil, fmt.Errorf("unknown geocoding provider %q", name)
+	}
+}
+
+func newUserRepository(conf *config.Config) (storage.UserRepository, error) {
+	switch conf.UserStore.Driver {
+	case "memory":
+		return storage.NewMemoryUserRepository(), nil
+	case "bolt":
+		return storage.NewBoltUserRepository(conf.UserStore.File)
+	default:
+		return nil, fmt.Errorf("unknown user store %q", conf.UserStore.Driver)
+	}
+}
+
+func newHistoryRepository(conf *config.Config) (storage.HistoryRepository, error) {
+	switch conf.HistoryStore.Driver {
+	case "memory":
+		return storage.NewMemoryHistoryRepository(conf.HistoryLimit), nil
+	case "file":
+		return storage.NewFileHistoryRepository(conf.HistoryStore.Dir, conf.HistoryLimit)
+	default:
+		return nil, fmt.Errorf("unknown history store %q", conf.HistoryStore.Driver)
+	}
+}
+
+func newRevocationStore(conf *config.Config) (storage.RevocationStore, error) {
+	switch conf.Revocation.Driver {
+	case "memory":
+		return storage.NewMemoryRevocationStore(), nil
+	case "file":
+		return storage.NewFileRevocationStore(conf.Revocation.File)
+	default:
+		return nil, fmt.Errorf("unknown revocation store %q", conf.Revocation.Driver)
+	}
+}
+
+func newAPIKeyRepository(conf *config.Config) (storage.APIKeyRepository, error) {
+	switch conf.APIKeyStore.Driver {
+	case "memory":
+		return storage.NewMemoryAPIKeyRepository(), nil
+	case "file":
+		return storage.NewFileAPIKeyRepository(conf.APIKeyStore.File)
+	default:
+		return nil, fmt.Errorf("unknown api key store %q", conf.APIKeyStore.Driver)
+	}
+}
+
+func newUsageRepository(conf *config.Config, logger *zap.Logger) (storage.UsageRepository, error) {
+	switch conf.UsageStore.Driver {
+	case "memory":
+		return storage.NewMemoryUsageRepository(), nil
+	case "file":
+		return storage.NewFileUsageRepository(conf.UsageStore.File, conf.UsageStore.FlushInterval, logger)
+	default:
+		return nil, fmt.Errorf("unknown usage store %q", conf.UsageStore.Driver)
+	}
+}
+
+func newAuditLog(conf *config.Config, logger *zap.Logger) (storage.AuditLog, error) {
+	switch conf.AuditLog.Driver {
+	case "memory":
+		return storage.NewMemoryAuditLog(), nil
+	case "file":
+		return storage.NewFileAuditLog(conf.AuditLog.File, int64(conf.AuditLog.MaxSize), conf.AuditLog.MaxFiles, logger)
+	default:
+		return nil, fmt.Errorf("unknown audit log %q", conf.AuditLog.Driver)
+	}
 }
 
 type ReverseProxy struct {
@@ -260,7 +1913,7 @@ func (rp *ReverseProxy) ReverseProxy(next http.Handler) http.Handler {
 			rp.c.SwaggerUI(w, r)
 			return
 		}
-		if strings.HasPrefix(r.URL.Path, "/api") {
+		if strings.HasPrefix(r.URL.Path, "/api") || strings.HasPrefix(r.URL.Path, "/.well-known") {
 			next.ServeHTTP(w, r)
 			return
 		}